@@ -2,16 +2,30 @@ package main
 
 import (
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
 	"time"
 
 	"github.com/yosadchyi/space-booking/pkg/booking"
 	"github.com/yosadchyi/space-booking/pkg/db"
+	"github.com/yosadchyi/space-booking/pkg/notification"
 )
 
 const defaultConnInfo = "host=127.0.0.1 port=5432 user=postgres password=postgres dbname=postgres sslmode=disable"
 const defaultBindAddr = ":8080"
+const defaultGRPCBindAddr = ":9090"
+const defaultImportInterval = "1h"
+const defaultHoldTTL = "15m"
+const defaultHoldSweepInterval = "1m"
+const defaultFlownInterval = "1h"
+const defaultNoShowWindow = "168h"
+const defaultWebhookInterval = "5s"
+const defaultOutboxInterval = "1s"
+const defaultRefundInterval = "10s"
+const defaultWaitlistInterval = "10s"
+const defaultMailFrom = "Space Booking <no-reply@space-booking.local>"
 
 func getenv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -20,12 +34,70 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+func getDuration(key, fallback string) time.Duration {
+	duration, err := time.ParseDuration(getenv(key, fallback))
+	if err != nil {
+		log.Fatalf("can't parse %s", key)
+	}
+	return duration
+}
+
+// runPeriodically runs job with given interval, errors are logged and job is retried on the next tick
+func runPeriodically(interval time.Duration, name string, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := job(); err != nil {
+			log.Printf("%s failed: %s", name, err)
+		}
+	}
+}
+
+// notifier returns notifier sending emails through SMTP server at SMTP_ADDR or writing them into MAIL_DIR,
+// emails are logged if neither is given
+func notifier() notification.Notifier {
+	from := getenv("MAIL_FROM", defaultMailFrom)
+	if addr := getenv("SMTP_ADDR", ""); addr != "" {
+		var auth smtp.Auth
+		if username := getenv("SMTP_USERNAME", ""); username != "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				log.Fatalf("can't parse SMTP_ADDR: %s", err)
+			}
+			auth = smtp.PlainAuth("", username, getenv("SMTP_PASSWORD", ""), host)
+		}
+		return notification.NewSMTPNotifier(addr, from, auth)
+	}
+	if dir := getenv("MAIL_DIR", ""); dir != "" {
+		return notification.NewFileNotifier(dir, from)
+	}
+	return notification.NewLogNotifier()
+}
+
+// serveGRPC serves gRPC API at given address
+func serveGRPC(service *booking.Service, bindAddr string) {
+	listener, err := net.Listen("tcp", bindAddr)
+	if err != nil {
+		log.Fatalf("can't listen at '%s' for gRPC: %s", bindAddr, err)
+	}
+	log.Printf("gRPC server listening at '%s'...", bindAddr)
+	err = booking.NewGRPCServer(service).Serve(listener)
+	if err != nil {
+		log.Fatal("can't start gRPC server")
+	}
+}
+
 func main() {
 	database, err := db.Connect(getenv("DB_CONN_INFO", defaultConnInfo))
 	if err != nil {
 		log.Fatal("can't connect to database")
 	}
-	service := booking.NewService(database)
+	config := booking.DefaultConfig()
+	config.HoldTTL = getDuration("HOLD_TTL", defaultHoldTTL)
+	config.NoShowWindow = getDuration("NO_SHOW_WINDOW", defaultNoShowWindow)
+	config.Notifier = notifier()
+	config.AdminToken = getenv("ADMIN_TOKEN", "")
+	service := booking.NewService(database, config)
 	for i := 0; i < 10; i++ {
 		err := service.PingDb()
 		if err == nil {
@@ -34,6 +106,19 @@ func main() {
 		time.Sleep(time.Second)
 	}
 	service.Init()
+	go runPeriodically(getDuration("IMPORT_INTERVAL", defaultImportInterval), "data import", service.Import)
+	go runPeriodically(getDuration("HOLD_SWEEP_INTERVAL", defaultHoldSweepInterval), "hold sweep",
+		service.ReleaseExpiredHolds)
+	go runPeriodically(getDuration("FLOWN_INTERVAL", defaultFlownInterval), "flown bookings update",
+		service.MarkFlownBookings)
+	go runPeriodically(getDuration("OUTBOX_INTERVAL", defaultOutboxInterval), "outbox relay", service.RelayOutbox)
+	go runPeriodically(getDuration("WEBHOOK_INTERVAL", defaultWebhookInterval), "webhook delivery",
+		service.DeliverWebhooks)
+	go runPeriodically(getDuration("REFUND_INTERVAL", defaultRefundInterval), "refund of cancelled bookings",
+		service.RefundCancelled)
+	go runPeriodically(getDuration("WAITLIST_INTERVAL", defaultWaitlistInterval), "waitlist processing",
+		service.ProcessWaitlist)
+	go serveGRPC(service, getenv("GRPC_BIND_ADDR", defaultGRPCBindAddr))
 	handler := booking.NewHandler(service)
 	bindAddr := getenv("HTTP_BIND_ADDR", defaultBindAddr)
 	log.Printf("listening at '%s'...", bindAddr)
@@ -0,0 +1,23 @@
+ALTER TABLE launch DROP capacity;
+
+ALTER TABLE booking ADD first_name VARCHAR(256);
+ALTER TABLE booking ADD last_name VARCHAR(256);
+ALTER TABLE booking ADD gender gender;
+ALTER TABLE booking ADD birthday DATE;
+
+UPDATE booking b SET
+    first_name = p.first_name,
+    last_name = p.last_name,
+    gender = p.gender,
+    birthday = p.birthday
+FROM (SELECT DISTINCT ON (booking_id) * FROM booking_passenger ORDER BY booking_id, id) p
+WHERE p.booking_id = b.id;
+
+DELETE FROM booking WHERE first_name IS NULL;
+
+ALTER TABLE booking ALTER first_name SET NOT NULL;
+ALTER TABLE booking ALTER last_name SET NOT NULL;
+ALTER TABLE booking ALTER gender SET NOT NULL;
+ALTER TABLE booking ALTER birthday SET NOT NULL;
+
+DROP TABLE IF EXISTS booking_passenger;
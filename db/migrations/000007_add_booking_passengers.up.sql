@@ -0,0 +1,21 @@
+CREATE TABLE booking_passenger
+(
+    id UUID NOT NULL PRIMARY KEY,
+    booking_id UUID NOT NULL REFERENCES booking(id) ON DELETE CASCADE,
+    first_name VARCHAR(256) NOT NULL,
+    last_name VARCHAR(256) NOT NULL,
+    gender gender NOT NULL,
+    birthday DATE NOT NULL
+);
+
+CREATE INDEX booking_passenger_booking_id_idx ON booking_passenger(booking_id);
+
+INSERT INTO booking_passenger (id, booking_id, first_name, last_name, gender, birthday)
+    SELECT id, id, first_name, last_name, gender, birthday FROM booking;
+
+ALTER TABLE booking DROP first_name;
+ALTER TABLE booking DROP last_name;
+ALTER TABLE booking DROP gender;
+ALTER TABLE booking DROP birthday;
+
+ALTER TABLE launch ADD capacity INT NOT NULL DEFAULT '1';
@@ -0,0 +1,3 @@
+DROP INDEX booking_launch_id_idx;
+ALTER TABLE launch DROP destination_id;
+ALTER TABLE launchpad DROP capacity;
@@ -0,0 +1,11 @@
+ALTER TABLE launchpad ADD capacity INT NOT NULL DEFAULT '4';
+
+ALTER TABLE launch ADD destination_id UUID REFERENCES destination(id);
+
+UPDATE launch l SET
+    destination_id = b.destination_id,
+    capacity = (SELECT capacity FROM launchpad WHERE id = l.launchpad_id)
+FROM booking b
+WHERE b.launch_id = l.id;
+
+CREATE INDEX booking_launch_id_idx ON booking(launch_id);
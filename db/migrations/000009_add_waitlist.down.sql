@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS waitlist_passenger;
+DROP TABLE IF EXISTS waitlist_entry;
+ALTER TABLE launch DROP external_id;
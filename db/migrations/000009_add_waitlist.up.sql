@@ -0,0 +1,31 @@
+ALTER TABLE launch ADD external_id VARCHAR(24) UNIQUE;
+
+-- SpaceX launches imported before are not linked to SpaceX ids, they are imported again on startup
+DELETE FROM launch WHERE destination_id IS NULL;
+
+CREATE TABLE waitlist_entry
+(
+    id UUID NOT NULL PRIMARY KEY,
+    launchpad_id CHAR(24) NOT NULL REFERENCES launchpad(id),
+    destination_id UUID NOT NULL REFERENCES destination(id),
+    date_from DATE NOT NULL,
+    date_to DATE NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'waiting',
+    booking_id UUID REFERENCES booking(id) ON DELETE SET NULL,
+    outcome_code VARCHAR(64),
+    processed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX waitlist_entry_launchpad_id_status_idx ON waitlist_entry(launchpad_id, status, created_at);
+
+CREATE TABLE waitlist_passenger
+(
+    waitlist_entry_id UUID NOT NULL REFERENCES waitlist_entry(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    first_name VARCHAR(256) NOT NULL,
+    last_name VARCHAR(256) NOT NULL,
+    gender gender NOT NULL,
+    birthday DATE NOT NULL,
+    PRIMARY KEY (waitlist_entry_id, position)
+);
@@ -0,0 +1,4 @@
+DELETE FROM booking WHERE status = 'held';
+DROP INDEX booking_status_hold_expires_at_idx;
+ALTER TABLE booking DROP hold_expires_at;
+ALTER TABLE booking DROP status;
@@ -0,0 +1,3 @@
+ALTER TABLE booking ADD status VARCHAR(16) NOT NULL DEFAULT 'confirmed';
+ALTER TABLE booking ADD hold_expires_at TIMESTAMP;
+CREATE INDEX booking_status_hold_expires_at_idx ON booking(status, hold_expires_at);
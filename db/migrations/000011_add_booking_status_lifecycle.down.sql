@@ -0,0 +1,11 @@
+DROP INDEX booking_status_launch_date_idx;
+
+DELETE FROM booking WHERE launch_id IS NULL OR status = 'cancelled';
+UPDATE booking SET status = 'confirmed' WHERE status IN ('flown', 'no_show');
+
+ALTER TABLE booking DROP CONSTRAINT booking_launch_id_fkey;
+ALTER TABLE booking ADD CONSTRAINT booking_launch_id_fkey
+    FOREIGN KEY (launch_id) REFERENCES launch(id) ON DELETE CASCADE;
+ALTER TABLE booking ALTER launch_id SET NOT NULL;
+
+ALTER TABLE booking DROP CONSTRAINT booking_status_check;
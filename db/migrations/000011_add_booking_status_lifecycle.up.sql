@@ -0,0 +1,11 @@
+UPDATE booking SET status = 'cancelled' WHERE status = 'held' AND hold_expires_at <= now();
+
+ALTER TABLE booking ADD CONSTRAINT booking_status_check
+    CHECK (status IN ('held', 'confirmed', 'cancelled', 'flown', 'no_show'));
+
+ALTER TABLE booking ALTER launch_id DROP NOT NULL;
+ALTER TABLE booking DROP CONSTRAINT booking_launch_id_fkey;
+ALTER TABLE booking ADD CONSTRAINT booking_launch_id_fkey
+    FOREIGN KEY (launch_id) REFERENCES launch(id) ON DELETE SET NULL;
+
+CREATE INDEX booking_status_launch_date_idx ON booking(status, launch_date);
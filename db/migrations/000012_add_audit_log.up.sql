@@ -0,0 +1,18 @@
+CREATE TABLE audit_log
+(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    booking_id UUID NOT NULL,
+    entity VARCHAR(32) NOT NULL,
+    entity_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    actor VARCHAR(256) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    before JSONB,
+    after JSONB
+);
+
+CREATE INDEX audit_log_booking_id_idx ON audit_log(booking_id, id);
+
+-- audit log is append-only
+CREATE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
@@ -0,0 +1,4 @@
+ALTER TABLE booking DROP currency;
+ALTER TABLE booking DROP price;
+ALTER TABLE launchpad DROP fare_modifier_percent;
+ALTER TABLE destination DROP base_fare;
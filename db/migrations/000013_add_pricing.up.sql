@@ -0,0 +1,15 @@
+-- all amounts are in cents
+ALTER TABLE destination ADD base_fare BIGINT NOT NULL DEFAULT '0';
+
+UPDATE destination SET base_fare = '15000000' WHERE name = 'Moon';
+UPDATE destination SET base_fare = '50000000' WHERE name = 'Mars';
+UPDATE destination SET base_fare = '80000000' WHERE name = 'Asteroid Belt';
+UPDATE destination SET base_fare = '110000000' WHERE name = 'Ganymede';
+UPDATE destination SET base_fare = '120000000' WHERE name = 'Europa';
+UPDATE destination SET base_fare = '150000000' WHERE name = 'Titan';
+UPDATE destination SET base_fare = '200000000' WHERE name = 'Pluto';
+
+ALTER TABLE launchpad ADD fare_modifier_percent INT NOT NULL DEFAULT '0';
+
+ALTER TABLE booking ADD price BIGINT NOT NULL DEFAULT '0';
+ALTER TABLE booking ADD currency CHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,14 @@
+CREATE TABLE payment_transaction
+(
+    id UUID NOT NULL PRIMARY KEY,
+    booking_id UUID NOT NULL REFERENCES booking(id),
+    type VARCHAR(16) NOT NULL,
+    reference VARCHAR(256) NOT NULL,
+    amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    message VARCHAR(256) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX payment_transaction_booking_id_idx ON payment_transaction(booking_id, created_at);
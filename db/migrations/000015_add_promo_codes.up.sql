@@ -0,0 +1,36 @@
+CREATE TABLE promo_code
+(
+    code VARCHAR(32) NOT NULL PRIMARY KEY,
+    description VARCHAR(256) NOT NULL DEFAULT '',
+    -- discount is either percent of the fare or fixed amount in cents
+    percent_off INT NOT NULL DEFAULT '0' CHECK (percent_off BETWEEN 0 AND 100),
+    amount_off BIGINT NOT NULL DEFAULT '0' CHECK (amount_off >= 0),
+    -- code can be redeemed within this window
+    valid_from TIMESTAMP,
+    valid_to TIMESTAMP,
+    -- code applies to launches within this window
+    launch_date_from DATE,
+    launch_date_to DATE,
+    destination_id UUID REFERENCES destination(id),
+    launchpad_id CHAR(24) REFERENCES launchpad(id),
+    max_uses INT CHECK (max_uses > 0),
+    uses INT NOT NULL DEFAULT '0',
+    stackable BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK (max_uses IS NULL OR uses <= max_uses)
+);
+
+CREATE TABLE promo_redemption
+(
+    id UUID NOT NULL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL REFERENCES promo_code(code),
+    booking_id UUID NOT NULL REFERENCES booking(id),
+    amount BIGINT NOT NULL,
+    -- redemption is released when booking is cancelled, so code can be used again
+    released BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX promo_redemption_code_idx ON promo_redemption(code, created_at);
+CREATE INDEX promo_redemption_booking_id_idx ON promo_redemption(booking_id);
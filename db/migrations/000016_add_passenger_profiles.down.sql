@@ -0,0 +1,3 @@
+ALTER TABLE waitlist_passenger DROP profile_id;
+ALTER TABLE booking_passenger DROP profile_id;
+DROP TABLE IF EXISTS passenger_profile;
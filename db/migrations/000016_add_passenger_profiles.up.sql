@@ -0,0 +1,32 @@
+CREATE TABLE passenger_profile
+(
+    id UUID NOT NULL PRIMARY KEY,
+    first_name VARCHAR(256) NOT NULL,
+    last_name VARCHAR(256) NOT NULL,
+    gender gender NOT NULL,
+    birthday DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- passengers are identified by name and birthday
+CREATE INDEX passenger_profile_identity_idx ON passenger_profile(lower(last_name), lower(first_name), birthday);
+
+INSERT INTO passenger_profile (id, first_name, last_name, gender, birthday)
+    SELECT gen_random_uuid(), first_name, last_name, gender, birthday FROM (
+        SELECT DISTINCT ON (lower(last_name), lower(first_name), birthday) first_name, last_name, gender, birthday
+        FROM booking_passenger
+        ORDER BY lower(last_name), lower(first_name), birthday
+    ) p;
+
+-- name, gender and birthday are kept on the booking as they were at the time of booking
+ALTER TABLE booking_passenger ADD profile_id UUID REFERENCES passenger_profile(id);
+
+UPDATE booking_passenger b SET profile_id = p.id FROM passenger_profile p
+    WHERE lower(p.last_name) = lower(b.last_name) AND lower(p.first_name) = lower(b.first_name)
+        AND p.birthday = b.birthday;
+
+ALTER TABLE booking_passenger ALTER profile_id SET NOT NULL;
+
+CREATE INDEX booking_passenger_profile_id_idx ON booking_passenger(profile_id);
+
+ALTER TABLE waitlist_passenger ADD profile_id UUID REFERENCES passenger_profile(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS loyalty_points;
+ALTER TABLE destination DROP distance_km;
@@ -0,0 +1,29 @@
+-- average distance from Earth
+ALTER TABLE destination ADD distance_km BIGINT NOT NULL DEFAULT '0';
+
+UPDATE destination SET distance_km = '384400' WHERE name = 'Moon';
+UPDATE destination SET distance_km = '225000000' WHERE name = 'Mars';
+UPDATE destination SET distance_km = '400000000' WHERE name = 'Asteroid Belt';
+UPDATE destination SET distance_km = '628300000' WHERE name = 'Europa';
+UPDATE destination SET distance_km = '628300000' WHERE name = 'Ganymede';
+UPDATE destination SET distance_km = '1272000000' WHERE name = 'Titan';
+UPDATE destination SET distance_km = '5906400000' WHERE name = 'Pluto';
+
+CREATE TABLE loyalty_points
+(
+    id UUID NOT NULL PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES passenger_profile(id),
+    booking_id UUID NOT NULL REFERENCES booking(id),
+    points BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (profile_id, booking_id)
+);
+
+-- points for bookings flown before, calculated with default loyalty policy
+INSERT INTO loyalty_points (id, profile_id, booking_id, points)
+    SELECT gen_random_uuid(), p.profile_id, b.id, GREATEST(50, d.distance_km * 10 / 1000000)
+    FROM booking b
+        JOIN booking_passenger p ON p.booking_id = b.id
+        JOIN destination d ON d.id = b.destination_id
+    WHERE b.status = 'flown'
+    ON CONFLICT DO NOTHING;
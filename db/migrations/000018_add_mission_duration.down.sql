@@ -0,0 +1 @@
+ALTER TABLE destination DROP mission_days;
@@ -0,0 +1,10 @@
+-- days passengers are away from the launch date until return
+ALTER TABLE destination ADD mission_days INT NOT NULL DEFAULT '1' CHECK (mission_days > 0);
+
+UPDATE destination SET mission_days = '10' WHERE name = 'Moon';
+UPDATE destination SET mission_days = '520' WHERE name = 'Mars';
+UPDATE destination SET mission_days = '900' WHERE name = 'Asteroid Belt';
+UPDATE destination SET mission_days = '2200' WHERE name = 'Europa';
+UPDATE destination SET mission_days = '2200' WHERE name = 'Ganymede';
+UPDATE destination SET mission_days = '3000' WHERE name = 'Titan';
+UPDATE destination SET mission_days = '9000' WHERE name = 'Pluto';
@@ -0,0 +1,15 @@
+-- passengers with genders other than male and female can't be migrated down
+CREATE TYPE gender AS ENUM ('Maile', 'Female');
+
+ALTER TABLE booking_passenger DROP CONSTRAINT booking_passenger_gender_fkey;
+ALTER TABLE waitlist_passenger DROP CONSTRAINT waitlist_passenger_gender_fkey;
+ALTER TABLE passenger_profile DROP CONSTRAINT passenger_profile_gender_fkey;
+
+ALTER TABLE booking_passenger ALTER gender TYPE gender
+    USING (CASE WHEN gender = 'Male' THEN 'Maile' ELSE gender END)::gender;
+ALTER TABLE waitlist_passenger ALTER gender TYPE gender
+    USING (CASE WHEN gender = 'Male' THEN 'Maile' ELSE gender END)::gender;
+ALTER TABLE passenger_profile ALTER gender TYPE gender
+    USING (CASE WHEN gender = 'Male' THEN 'Maile' ELSE gender END)::gender;
+
+DROP TABLE IF EXISTS gender_option;
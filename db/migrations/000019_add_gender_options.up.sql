@@ -0,0 +1,25 @@
+-- genders are kept in a table, so new values can be added without changing the schema
+CREATE TABLE gender_option
+(
+    value VARCHAR(32) NOT NULL PRIMARY KEY,
+    position INT NOT NULL DEFAULT '0'
+);
+
+INSERT INTO gender_option (value, position) VALUES
+    ('Male', 1),
+    ('Female', 2),
+    ('NonBinary', 3),
+    ('Unspecified', 4);
+
+ALTER TABLE booking_passenger ALTER gender TYPE VARCHAR(32)
+    USING CASE WHEN gender = 'Maile' THEN 'Male' ELSE gender::VARCHAR END;
+ALTER TABLE waitlist_passenger ALTER gender TYPE VARCHAR(32)
+    USING CASE WHEN gender = 'Maile' THEN 'Male' ELSE gender::VARCHAR END;
+ALTER TABLE passenger_profile ALTER gender TYPE VARCHAR(32)
+    USING CASE WHEN gender = 'Maile' THEN 'Male' ELSE gender::VARCHAR END;
+
+ALTER TABLE booking_passenger ADD FOREIGN KEY (gender) REFERENCES gender_option(value);
+ALTER TABLE waitlist_passenger ADD FOREIGN KEY (gender) REFERENCES gender_option(value);
+ALTER TABLE passenger_profile ADD FOREIGN KEY (gender) REFERENCES gender_option(value);
+
+DROP TYPE gender;
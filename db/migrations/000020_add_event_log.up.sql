@@ -0,0 +1,13 @@
+-- events of bookings, launches and launchpads streamed to clients, clients resume the stream from the last seen id
+CREATE TABLE event_log
+(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- event log is append-only
+CREATE RULE event_log_no_update AS ON UPDATE TO event_log DO INSTEAD NOTHING;
+CREATE RULE event_log_no_delete AS ON DELETE TO event_log DO INSTEAD NOTHING;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_attempt;
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook_cursor;
+DROP TABLE IF EXISTS webhook_subscription;
@@ -0,0 +1,45 @@
+CREATE TABLE webhook_subscription
+(
+    id UUID NOT NULL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    event_types VARCHAR(64)[] NOT NULL,
+    secret VARCHAR(256) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- events of the event log up to last_event_id are dispatched to subscriptions
+CREATE TABLE webhook_cursor
+(
+    id INT NOT NULL PRIMARY KEY CHECK (id = 1),
+    last_event_id BIGINT NOT NULL
+);
+
+INSERT INTO webhook_cursor (id, last_event_id) SELECT 1, COALESCE(MAX(id), 0) FROM event_log;
+
+CREATE TABLE webhook_delivery
+(
+    id UUID NOT NULL PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES webhook_subscription(id),
+    event_id BIGINT NOT NULL REFERENCES event_log(id),
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT '0',
+    next_attempt_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMP,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_delivery_due_idx ON webhook_delivery(next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE webhook_attempt
+(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES webhook_delivery(id),
+    attempted_at TIMESTAMP NOT NULL DEFAULT now(),
+    status_code INT NOT NULL,
+    error TEXT NOT NULL,
+    duration_ms BIGINT NOT NULL
+);
+
+CREATE INDEX webhook_attempt_delivery_id_idx ON webhook_attempt(delivery_id, id);
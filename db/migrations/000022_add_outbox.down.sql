@@ -0,0 +1,11 @@
+CREATE TABLE webhook_cursor
+(
+    id INT NOT NULL PRIMARY KEY CHECK (id = 1),
+    last_event_id BIGINT NOT NULL
+);
+
+-- unpublished events are dispatched to webhooks once again
+INSERT INTO webhook_cursor (id, last_event_id)
+    SELECT 1, COALESCE(MIN(event_id) - 1, (SELECT MAX(id) FROM event_log), 0) FROM outbox WHERE published_at IS NULL;
+
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,20 @@
+-- events waiting to be published to sinks, written in the same transaction as the change. Entries of the same
+-- aggregate are published in order, entry is retried at next_attempt_at until it is published
+CREATE TABLE outbox
+(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    aggregate_id VARCHAR(64) NOT NULL,
+    event_id BIGINT NOT NULL REFERENCES event_log(id),
+    attempts INT NOT NULL DEFAULT '0',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    last_error TEXT,
+    published_at TIMESTAMP
+);
+
+CREATE INDEX outbox_unpublished_idx ON outbox(aggregate_id, id) WHERE published_at IS NULL;
+
+-- events which are not dispatched to webhooks yet are relayed through the outbox
+INSERT INTO outbox (aggregate_id, event_id)
+    SELECT e.entity_id, e.id FROM event_log e WHERE e.id > (SELECT last_event_id FROM webhook_cursor) ORDER BY e.id;
+
+DROP TABLE webhook_cursor;
@@ -0,0 +1,2 @@
+ALTER TABLE booking DROP locale;
+ALTER TABLE booking DROP contact_email;
@@ -0,0 +1,3 @@
+-- customers are emailed about their bookings at contact_email in given locale, empty email means no emails
+ALTER TABLE booking ADD contact_email VARCHAR(254) NOT NULL DEFAULT '';
+ALTER TABLE booking ADD locale VARCHAR(16) NOT NULL DEFAULT 'en';
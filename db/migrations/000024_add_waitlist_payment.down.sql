@@ -0,0 +1,3 @@
+ALTER TABLE waitlist_entry DROP locale;
+ALTER TABLE waitlist_entry DROP contact_email;
+ALTER TABLE waitlist_entry DROP payment_token;
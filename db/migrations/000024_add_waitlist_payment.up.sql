@@ -0,0 +1,5 @@
+-- waitlist entries are converted into paid bookings with payment token given when entry is added,
+-- customers are emailed about the booking like for any other booking
+ALTER TABLE waitlist_entry ADD payment_token VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE waitlist_entry ADD contact_email VARCHAR(254) NOT NULL DEFAULT '';
+ALTER TABLE waitlist_entry ADD locale VARCHAR(16) NOT NULL DEFAULT 'en';
@@ -0,0 +1,15 @@
+-- refunds of cancelled bookings, written in the same transaction as the cancellation, so refund is not lost
+-- if payment provider fails. Refund is retried at next_attempt_at until it is made, amount is the refunded sum
+CREATE TABLE pending_refund
+(
+    booking_id UUID NOT NULL PRIMARY KEY REFERENCES booking(id),
+    percent INT NOT NULL,
+    amount BIGINT NOT NULL DEFAULT '0',
+    attempts INT NOT NULL DEFAULT '0',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    last_error TEXT,
+    refunded_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX pending_refund_due_idx ON pending_refund(next_attempt_at) WHERE refunded_at IS NULL;
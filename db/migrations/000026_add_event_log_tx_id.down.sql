@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS event_log_tx_id_idx;
+ALTER TABLE event_log DROP COLUMN IF EXISTS tx_id;
@@ -0,0 +1,5 @@
+-- transaction which recorded the event, events are streamed in order of transactions which are finished,
+-- so event of a long transaction committed after events with greater ids is not skipped
+ALTER TABLE event_log ADD tx_id XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX event_log_tx_id_idx ON event_log(tx_id, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS webhook_delivery_aggregate_idx;
+ALTER TABLE webhook_delivery DROP COLUMN IF EXISTS seq;
+ALTER TABLE webhook_delivery DROP COLUMN IF EXISTS aggregate_id;
@@ -0,0 +1,11 @@
+-- deliveries of events of the same aggregate, e.g. booking, are made to the subscription one by one in order
+-- they are added (seq), the next one waits while the previous one is pending
+ALTER TABLE webhook_delivery ADD aggregate_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE webhook_delivery ADD seq BIGSERIAL NOT NULL;
+
+UPDATE webhook_delivery d SET aggregate_id = COALESCE(
+    (SELECT o.aggregate_id FROM outbox o WHERE o.event_id = d.event_id LIMIT 1),
+    (SELECT e.entity_id FROM event_log e WHERE e.id = d.event_id));
+
+CREATE INDEX webhook_delivery_aggregate_idx ON webhook_delivery(subscription_id, aggregate_id, seq)
+    WHERE status = 'pending';
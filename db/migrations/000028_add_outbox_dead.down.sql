@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX outbox_unpublished_idx ON outbox(aggregate_id, id) WHERE published_at IS NULL;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS dead_at;
+ALTER TABLE outbox DROP COLUMN IF EXISTS published_sinks;
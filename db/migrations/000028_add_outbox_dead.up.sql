@@ -0,0 +1,7 @@
+-- sinks which accepted the entry are not published to again, entry which fails to be published
+-- the maximum number of attempts is dead and no longer holds back later entries of its aggregate
+ALTER TABLE outbox ADD published_sinks VARCHAR(128)[] NOT NULL DEFAULT '{}';
+ALTER TABLE outbox ADD dead_at TIMESTAMP;
+
+DROP INDEX IF EXISTS outbox_unpublished_idx;
+CREATE INDEX outbox_pending_idx ON outbox(aggregate_id, id) WHERE published_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,12 @@
+-- seats freed by cancellations, expired holds and removed launches are recorded along with the change
+-- and offered to the waitlist in background, records are deleted once the waitlist is processed
+CREATE TABLE freed_seats
+(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    launchpad_id CHAR(24) NOT NULL,
+    launch_date DATE NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX freed_seats_next_attempt_at_idx ON freed_seats(next_attempt_at);
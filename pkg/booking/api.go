@@ -1,32 +1,211 @@
 package booking
 
+import "time"
+
 // Request represents request for booking the flight
 type Request struct {
-	FirstName     string
-	LastName      string
-	Gender        Gender
-	Birthday      Date
-	LaunchpadId   string
-	DestinationId string
-	LaunchDate    Date
+	FirstName     string   `json:"firstName"`
+	LastName      string   `json:"lastName"`
+	Gender        Gender   `json:"gender"`
+	Birthday      Date     `json:"birthday"`
+	LaunchpadId   string   `json:"launchpadId"`
+	DestinationId string   `json:"destinationId"`
+	LaunchDate    Date     `json:"launchDate"`
+	PaymentToken  string   `json:"paymentToken"`
+	PromoCodes    []string `json:"promoCodes"`
+	ContactEmail  string   `json:"contactEmail"`
+	Locale        string   `json:"locale"`
+}
+
+// PassengerRequest represents single passenger of the group booking request, passenger is either referenced
+// by ProfileId or given by name, gender and birthday
+type PassengerRequest struct {
+	ProfileId string `json:"profileId"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Gender    Gender `json:"gender"`
+	Birthday  Date   `json:"birthday"`
+}
+
+// GroupRequest represents request for booking the flight for several passengers at once
+type GroupRequest struct {
+	Passengers    []PassengerRequest `json:"passengers"`
+	LaunchpadId   string             `json:"launchpadId"`
+	DestinationId string             `json:"destinationId"`
+	LaunchDate    Date               `json:"launchDate"`
+	PaymentToken  string             `json:"paymentToken"`
+	PromoCodes    []string           `json:"promoCodes"`
+	ContactEmail  string             `json:"contactEmail"`
+	Locale        string             `json:"locale"`
+}
+
+// WaitlistRequest represents request for putting passengers on the waitlist for launchpad and date range
+type WaitlistRequest struct {
+	Passengers    []PassengerRequest `json:"passengers"`
+	LaunchpadId   string             `json:"launchpadId"`
+	DestinationId string             `json:"destinationId"`
+	DateFrom      Date               `json:"dateFrom"`
+	DateTo        Date               `json:"dateTo"`
+	PaymentToken  string             `json:"paymentToken"`
+	ContactEmail  string             `json:"contactEmail"`
+	Locale        string             `json:"locale"`
+}
+
+// PassengerProfileRequest represents body of /passenger/ POST and /passenger/{id} PUT requests
+type PassengerProfileRequest struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Gender    Gender `json:"gender"`
+	Birthday  Date   `json:"birthday"`
+}
+
+// MergeRequest represents request for merging duplicate passengers into the passenger given in URL
+type MergeRequest struct {
+	PassengerIds []string `json:"passengerIds"`
+}
+
+// ConfirmRequest represents request for confirming the hold
+type ConfirmRequest struct {
+	PaymentToken string `json:"paymentToken"`
+}
+
+// StatusRequest represents request for changing status of the booking
+type StatusRequest struct {
+	Status BookingStatus `json:"status"`
+}
+
+// GraphQLRequest represents body of /graphql POST request, Variables are values of variables used in Query
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// WebhookRequest represents body of /admin/webhook POST request, Secret is used to sign delivered events
+type WebhookRequest struct {
+	Url        string      `json:"url"`
+	EventTypes []EventType `json:"eventTypes"`
+	Secret     string      `json:"secret"`
 }
 
 // SuccessResponse response in case of successful booking
 type SuccessResponse struct {
-	Id string
+	Id string `json:"id"`
+}
+
+// HoldResponse response in case of successful hold, hold has to be confirmed before it expires
+type HoldResponse struct {
+	Id         string    `json:"id"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	TTLSeconds int       `json:"ttlSeconds"`
+}
+
+// QuoteItem single item of the quote, Passenger is an index of the passenger in the request or -1 for items
+// applied to the whole booking, Amount is in cents and is negative for discounts
+type QuoteItem struct {
+	Passenger   int    `json:"passenger"`
+	Description string `json:"description"`
+	Amount      int64  `json:"amount"`
 }
 
-// ErrorResponse response in case of booking error
+// Quote represents response to /quote/ POST request, itemized price of the prospective booking
+type Quote struct {
+	Currency string      `json:"currency"`
+	Total    int64       `json:"total"`
+	Items    []QuoteItem `json:"items"`
+}
+
+// CancellationResponse is returned when booking is cancelled, contains refunded part of the paid price
+type CancellationResponse struct {
+	Id            string `json:"id"`
+	RefundPercent int    `json:"refundPercent"`
+	RefundAmount  int64  `json:"refundAmount"`
+	Currency      string `json:"currency"`
+}
+
+// PromoCodeRequest represents body of /promo/ POST request
+type PromoCodeRequest struct {
+	Code           string     `json:"code"`
+	Description    string     `json:"description"`
+	PercentOff     int        `json:"percentOff"`
+	AmountOff      int64      `json:"amountOff"`
+	ValidFrom      *time.Time `json:"validFrom"`
+	ValidTo        *time.Time `json:"validTo"`
+	LaunchDateFrom *Date      `json:"launchDateFrom"`
+	LaunchDateTo   *Date      `json:"launchDateTo"`
+	DestinationId  *string    `json:"destinationId"`
+	LaunchpadId    *string    `json:"launchpadId"`
+	MaxUses        *int       `json:"maxUses"`
+	Stackable      bool       `json:"stackable"`
+}
+
+// PromoCodeReport promo code along with total discount given by its redemptions which are not released
+type PromoCodeReport struct {
+	PromoCode
+	TotalDiscount int64 `json:"totalDiscount"`
+}
+
+// PromoCodeResponse represents response to /promo/{code} GET request
+type PromoCodeResponse struct {
+	PromoCodeReport
+	Redemptions []PromoRedemption `json:"redemptions"`
+}
+
+// ErrorResponse response in case of booking error, Fields lists invalid fields of the request
 type ErrorResponse struct {
-	Code    string
-	Message string
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
 }
 
 // AllLaunchpadsResponse represents response to /launchpad/ GET request
 type AllLaunchpadsResponse []Launchpad
 
+// AllGendersResponse represents response to /gender/ GET request
+type AllGendersResponse []Gender
+
 // AllDestinationsResponse represents response to /destination/ GET request
 type AllDestinationsResponse []Destination
 
+// AllLaunchesResponse represents response to /launch/ GET request
+type AllLaunchesResponse []Launch
+
 // AllBookingsResponse represents response to /booking/ GET request
 type AllBookingsResponse []Booking
+
+// AllWaitlistEntriesResponse represents response to /waitlist/ GET request
+type AllWaitlistEntriesResponse []WaitlistEntry
+
+// BookingHistoryResponse represents response to /booking/{id}/history GET request
+type BookingHistoryResponse []AuditEntry
+
+// AllPaymentsResponse represents response to /booking/{id}/payment GET request
+type AllPaymentsResponse []PaymentTransaction
+
+// AllPromoCodesResponse represents response to /promo/ GET request
+type AllPromoCodesResponse []PromoCodeReport
+
+// AllPassengersResponse represents response to /passenger/ GET request
+type AllPassengersResponse []PassengerProfile
+
+// PassengerDuplicatesResponse represents response to /passenger/duplicates GET request,
+// groups of passengers having the same name and birthday
+type PassengerDuplicatesResponse [][]PassengerProfile
+
+// AllWebhooksResponse represents response to /admin/webhook GET request
+type AllWebhooksResponse []WebhookSubscription
+
+// WebhookDeliveriesResponse represents response to /admin/webhook/{id}/delivery GET request
+type WebhookDeliveriesResponse []WebhookDelivery
+
+// LoyaltyResponse represents response to /passenger/{id}/loyalty GET request, flight history of the passenger
+// along with point balance and reached tier
+type LoyaltyResponse struct {
+	PassengerId      string              `json:"passengerId"`
+	Points           int64               `json:"points"`
+	Tier             string              `json:"tier"`
+	PriorityWaitlist bool                `json:"priorityWaitlist"`
+	NextTier         string              `json:"nextTier"`
+	PointsToNextTier int64               `json:"pointsToNextTier"`
+	Flights          []FlightHistoryItem `json:"flights"`
+}
@@ -0,0 +1,5 @@
+// Package bookingpb contains gRPC API of the booking service generated from booking.proto,
+// generation requires buf, protoc-gen-go and protoc-gen-go-grpc
+package bookingpb
+
+//go:generate buf generate --template buf.gen.yaml .
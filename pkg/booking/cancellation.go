@@ -0,0 +1,34 @@
+package booking
+
+import "time"
+
+// CancellationRule refunds Percent of the paid price when booking is cancelled at least MinDays before the launch date
+type CancellationRule struct {
+	MinDays int
+	Percent int
+}
+
+// CancellationPolicy describes how much of the paid price is refunded on cancellation, rules are checked in order,
+// first matching rule is applied, nothing is refunded if no rule matches
+type CancellationPolicy []CancellationRule
+
+// DefaultCancellationPolicy returns default cancellation policy
+func DefaultCancellationPolicy() CancellationPolicy {
+	return CancellationPolicy{
+		{MinDays: 90, Percent: 100},
+		{MinDays: 30, Percent: 75},
+		{MinDays: 7, Percent: 50},
+		{MinDays: 0, Percent: 0},
+	}
+}
+
+// RefundPercent returns percent of the paid price refunded when booking for given launch date is cancelled now
+func (p CancellationPolicy) RefundPercent(launchDate time.Time) int {
+	days := int(time.Until(launchDate).Hours() / 24)
+	for _, rule := range p {
+		if days >= rule.MinDays {
+			return rule.Percent
+		}
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package booking
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// legacyKeys are JSON keys which are not converted to legacy names by capitalizing the first letter
+var legacyKeys = map[string]string{
+	"ttlSeconds": "TTLSeconds",
+}
+
+var dateType = reflect.TypeOf(Date{})
+
+// legacyResponseWriter writes JSON responses in the format used before the API was versioned, for clients
+// of deprecated routes: keys are Go field names and dates are RFC 3339 timestamps at midnight UTC.
+// Request bodies need no conversion as JSON keys are matched case-insensitively and Date accepts timestamps
+type legacyResponseWriter struct {
+	http.ResponseWriter
+	// value is a value the response is encoded from, only its Date fields are converted to timestamps
+	value interface{}
+}
+
+func (w *legacyResponseWriter) Write(data []byte) (int, error) {
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.ResponseWriter.Write(data)
+	}
+	// numbers are kept as they are, so int64 values are not rounded to float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return w.ResponseWriter.Write(data)
+	}
+	legacy, err := json.Marshal(toLegacy(legacyDates(reflect.ValueOf(w.value), value)))
+	if err != nil {
+		return 0, err
+	}
+	if _, err = w.ResponseWriter.Write(legacy); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// legacyDates converts dates of decoded JSON value to timestamps, dates are found by walking value the JSON
+// is encoded from along with it
+func legacyDates(value reflect.Value, decoded interface{}) interface{} {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return decoded
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return decoded
+	}
+	if value.Type() == dateType {
+		if date, ok := decoded.(string); ok {
+			return date + "T00:00:00Z"
+		}
+		return decoded
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if object, ok := decoded.(map[string]interface{}); ok {
+			legacyFieldDates(value, object)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := decoded.([]interface{})
+		if !ok || len(items) != value.Len() {
+			return decoded
+		}
+		for i := range items {
+			items[i] = legacyDates(value.Index(i), items[i])
+		}
+	case reflect.Map:
+		object, ok := decoded.(map[string]interface{})
+		if !ok {
+			return decoded
+		}
+		iterator := value.MapRange()
+		for iterator.Next() {
+			key := fmt.Sprint(iterator.Key().Interface())
+			if item, ok := object[key]; ok {
+				object[key] = legacyDates(iterator.Value(), item)
+			}
+		}
+	}
+	return decoded
+}
+
+// legacyFieldDates converts dates of JSON object encoded from the struct, fields of embedded structs are
+// in the same object
+func legacyFieldDates(value reflect.Value, object map[string]interface{}) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			legacyFieldDates(value.Field(i), object)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if item, ok := object[name]; ok {
+			object[name] = legacyDates(value.Field(i), item)
+		}
+	}
+}
+
+// toLegacy converts keys of decoded JSON value to legacy names
+func toLegacy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[legacyKey(key)] = toLegacy(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = toLegacy(item)
+		}
+		return v
+	}
+	return value
+}
+
+func legacyKey(key string) string {
+	if legacy, ok := legacyKeys[key]; ok {
+		return legacy
+	}
+	if key == "" {
+		return key
+	}
+	return strings.ToUpper(key[:1]) + key[1:]
+}
@@ -0,0 +1,30 @@
+package booking
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLegacyResponseConvertsOnlyDates(t *testing.T) {
+	launchDate := Date(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC))
+	recorder := httptest.NewRecorder()
+	writeJsonResponse(&legacyResponseWriter{ResponseWriter: recorder}, 200, []PromoCode{{
+		Code:           "2025-12-31",
+		AmountOff:      9007199254740993,
+		LaunchDateFrom: &launchDate,
+	}})
+
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`"Code":"2025-12-31"`,
+		`"AmountOff":9007199254740993`,
+		`"LaunchDateFrom":"2030-01-02T00:00:00Z"`,
+		`"LaunchDateTo":null`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("legacy response does not contain %s: %s", expected, body)
+		}
+	}
+}
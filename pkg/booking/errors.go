@@ -0,0 +1,178 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind is a category of the domain error, it determines HTTP status of the error response
+type ErrorKind int
+
+const (
+	// Internal errors are failures of the infrastructure, e.g. database is unavailable
+	Internal ErrorKind = iota
+	// NotFound errors are returned when requested entity does not exist
+	NotFound
+	// Conflict errors are returned when request contradicts current state of the entity
+	Conflict
+	// Validation errors are returned when request is malformed or has invalid fields
+	Validation
+	// Unavailable errors are returned when request is valid but can't be fulfilled, e.g. launch is full
+	Unavailable
+	// Unauthorized errors are returned when admin request has no valid admin token
+	Unauthorized
+)
+
+// Error codes returned in ErrorResponse, codes are part of the API and are never changed or reused
+const (
+	CodeInternalError              = "INTERNAL_ERROR"
+	CodeRouteNotFound              = "ROUTE_NOT_FOUND"
+	CodeMethodNotAllowed           = "METHOD_NOT_ALLOWED"
+	CodeRequestIsInvalid           = "REQUEST_IS_INVALID"
+	CodeValidationFailed           = "VALIDATION_FAILED"
+	CodeBookingNotFound            = "BOOKING_NOT_FOUND"
+	CodeInvalidStatusTransition    = "INVALID_STATUS_TRANSITION"
+	CodeHoldNotFound               = "HOLD_NOT_FOUND"
+	CodeHoldExpired                = "HOLD_EXPIRED"
+	CodePaymentDeclined            = "PAYMENT_DECLINED"
+	CodeNoPassengers               = "NO_PASSENGERS"
+	CodeLaunchDateIsInPast         = "LAUNCH_DATE_IS_IN_PAST"
+	CodeDateRangeIsInvalid         = "DATE_RANGE_IS_INVALID"
+	CodeDestinationIsInvalid       = "DESTINATION_IS_INVALID"
+	CodeLaunchpadIsInvalid         = "LAUNCHPAD_IS_INVALID"
+	CodeLaunchpadNotAvailable      = "LAUNCHPAD_NOT_AVAILABLE"
+	CodeLaunchpadBusy              = "LAUNCHPAD_BUSY"
+	CodeLaunchFull                 = "LAUNCH_FULL"
+	CodeSameDestinationInWeek      = "SAME_DESTINATION_IN_WEEK"
+	CodeCapacityExceeded           = "CAPACITY_EXCEEDED"
+	CodePromoCodeNotFound          = "PROMO_CODE_NOT_FOUND"
+	CodePromoCodeAlreadyExists     = "PROMO_CODE_ALREADY_EXISTS"
+	CodePromoCodeIsInvalid         = "PROMO_CODE_IS_INVALID"
+	CodePromoCodeIsExpired         = "PROMO_CODE_IS_EXPIRED"
+	CodePromoCodeNotApplicable     = "PROMO_CODE_NOT_APPLICABLE"
+	CodePromoCodeIsExhausted       = "PROMO_CODE_IS_EXHAUSTED"
+	CodePromoCodesNotStackable     = "PROMO_CODES_NOT_STACKABLE"
+	CodePassengerNotFound          = "PASSENGER_NOT_FOUND"
+	CodePassengerIsInvalid         = "PASSENGER_IS_INVALID"
+	CodePassengerHasBookings       = "PASSENGER_HAS_BOOKINGS"
+	CodePassengerAlreadyTravelling = "PASSENGER_ALREADY_TRAVELLING"
+	CodeWebhookNotFound            = "WEBHOOK_NOT_FOUND"
+	CodeWebhookDeliveryNotFound    = "WEBHOOK_DELIVERY_NOT_FOUND"
+	CodeWebhookDeliveryNotDead     = "WEBHOOK_DELIVERY_NOT_DEAD"
+	CodeUnauthorized               = "UNAUTHORIZED"
+)
+
+// errorKinds is a catalogue of error codes along with their kinds
+var errorKinds = map[string]ErrorKind{
+	CodeInternalError:              Internal,
+	CodeRouteNotFound:              NotFound,
+	CodeMethodNotAllowed:           Validation,
+	CodeRequestIsInvalid:           Validation,
+	CodeValidationFailed:           Validation,
+	CodeBookingNotFound:            NotFound,
+	CodeInvalidStatusTransition:    Conflict,
+	CodeHoldNotFound:               NotFound,
+	CodeHoldExpired:                Conflict,
+	CodePaymentDeclined:            Unavailable,
+	CodeNoPassengers:               Validation,
+	CodeLaunchDateIsInPast:         Validation,
+	CodeDateRangeIsInvalid:         Validation,
+	CodeDestinationIsInvalid:       Validation,
+	CodeLaunchpadIsInvalid:         Validation,
+	CodeLaunchpadNotAvailable:      Unavailable,
+	CodeLaunchpadBusy:              Unavailable,
+	CodeLaunchFull:                 Unavailable,
+	CodeSameDestinationInWeek:      Unavailable,
+	CodeCapacityExceeded:           Validation,
+	CodePromoCodeNotFound:          NotFound,
+	CodePromoCodeAlreadyExists:     Conflict,
+	CodePromoCodeIsInvalid:         Validation,
+	CodePromoCodeIsExpired:         Unavailable,
+	CodePromoCodeNotApplicable:     Unavailable,
+	CodePromoCodeIsExhausted:       Unavailable,
+	CodePromoCodesNotStackable:     Validation,
+	CodePassengerNotFound:          NotFound,
+	CodePassengerIsInvalid:         Validation,
+	CodePassengerHasBookings:       Conflict,
+	CodePassengerAlreadyTravelling: Conflict,
+	CodeWebhookNotFound:            NotFound,
+	CodeWebhookDeliveryNotFound:    NotFound,
+	CodeWebhookDeliveryNotDead:     Conflict,
+	CodeUnauthorized:               Unauthorized,
+}
+
+// Error is a domain error returned by the service, it is written to the client as ErrorResponse
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+// newError creates domain error with given code, kind of the error is taken from the catalogue
+func newError(code string, format string, args ...interface{}) *Error {
+	kind, ok := errorKinds[code]
+	if !ok {
+		kind = Internal
+	}
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Status returns HTTP status of the error response
+func (e *Error) Status() int {
+	if e.Code == CodeMethodNotAllowed {
+		return http.StatusMethodNotAllowed
+	}
+	switch e.Kind {
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Validation:
+		return http.StatusBadRequest
+	case Unavailable:
+		return http.StatusUnprocessableEntity
+	case Unauthorized:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// Response returns error response written to the client
+func (e *Error) Response() *ErrorResponse {
+	return &ErrorResponse{
+		Code:    e.Code,
+		Message: e.Message,
+		Fields:  e.Fields,
+	}
+}
+
+// Extensions returns code of the error given in extensions of GraphQL errors
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// asError returns domain error wrapped by err, errors which are not domain errors are converted
+// to internal error hiding details of the failure
+func asError(err error) *Error {
+	var domainError *Error
+	if errors.As(err, &domainError) {
+		return domainError
+	}
+	return newError(CodeInternalError, "internal server error")
+}
+
+// isDomainError checks whether err is a domain error, i.e. it is caused by the request rather than by infrastructure
+func isDomainError(err error) bool {
+	var domainError *Error
+	return errors.As(err, &domainError) && domainError.Kind != Internal
+}
@@ -0,0 +1,496 @@
+package booking
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// graphqlSchema is a schema of the GraphQL API, amounts in cents and distances are Float as they may exceed
+// 32-bit Int of GraphQL
+const graphqlSchema = `
+	schema {
+		query: Query
+	}
+
+	type Query {
+		launchpads: [Launchpad!]!
+		destinations: [Destination!]!
+		launches: [Launch!]!
+		bookings(status: BookingStatus): [Booking!]!
+		booking(id: ID!): Booking
+	}
+
+	enum BookingStatus {
+		held
+		confirmed
+		cancelled
+		flown
+		no_show
+	}
+
+	type Launchpad {
+		id: ID!
+		name: String!
+		status: String!
+		capacity: Int!
+		fareModifierPercent: Int!
+	}
+
+	type Destination {
+		id: ID!
+		name: String!
+		baseFare: Float!
+		distanceKm: Float!
+		missionDays: Int!
+	}
+
+	type Launch {
+		id: ID!
+		externalId: String
+		date: String!
+		capacity: Int!
+		remainingSeats: Int!
+		launchpad: Launchpad
+		destination: Destination
+	}
+
+	type Passenger {
+		id: ID!
+		profileId: ID
+		firstName: String!
+		lastName: String!
+		gender: String!
+		birthday: String!
+	}
+
+	type Booking {
+		id: ID!
+		status: BookingStatus!
+		holdExpiresAt: String
+		launchDate: String!
+		remainingSeats: Int!
+		price: Float!
+		currency: String!
+		launchpad: Launchpad
+		destination: Destination
+		launch: Launch
+		passengers: [Passenger!]!
+	}
+`
+
+// maxQueryDepth is a maximum nesting of GraphQL queries
+const maxQueryDepth = 10
+
+// newGraphQLSchema parses GraphQL schema with resolvers backed by the service repositories
+func newGraphQLSchema(service *Service) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &graphqlResolver{service: service},
+		graphql.MaxDepth(maxQueryDepth))
+}
+
+// batchLoader loads entities by id for a single GraphQL request. Ids of entities referenced by resolved lists
+// are primed and all of them are fetched with a single query when the first one is loaded, so nested fields
+// of the list cost one query per entity type rather than one per item
+type batchLoader struct {
+	mu      sync.Mutex
+	fetch   func(ids []string) (map[string]interface{}, error)
+	pending map[string]bool
+	loaded  map[string]interface{}
+}
+
+func newBatchLoader(fetch func(ids []string) (map[string]interface{}, error)) *batchLoader {
+	return &batchLoader{
+		fetch:   fetch,
+		pending: make(map[string]bool),
+		loaded:  make(map[string]interface{}),
+	}
+}
+
+// prime schedules entities with given ids to be fetched along with the next load, empty ids are ignored
+func (l *batchLoader) prime(ids ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := l.loaded[id]; !ok && id != "" {
+			l.pending[id] = true
+		}
+	}
+}
+
+// load returns entity with given id, entities which are not found are nil
+func (l *batchLoader) load(id string) (interface{}, error) {
+	if id == "" {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if value, ok := l.loaded[id]; ok {
+		return value, nil
+	}
+	l.pending[id] = true
+	ids := make([]string, 0, len(l.pending))
+	for pendingId := range l.pending {
+		ids = append(ids, pendingId)
+	}
+	sort.Strings(ids)
+	values, err := l.fetch(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, pendingId := range ids {
+		l.loaded[pendingId] = values[pendingId]
+	}
+	l.pending = make(map[string]bool)
+	return l.loaded[id], nil
+}
+
+// graphqlLoaders are batch loaders of entities referenced by bookings and launches
+type graphqlLoaders struct {
+	launchpads   *batchLoader
+	destinations *batchLoader
+	launches     *batchLoader
+}
+
+type graphqlLoadersKey struct{}
+
+// withGraphQLLoaders returns context of the GraphQL request carrying new batch loaders, launchpads
+// and destinations of loaded launches are primed along with them
+func withGraphQLLoaders(ctx context.Context, service *Service) context.Context {
+	loaders := &graphqlLoaders{}
+	loaders.launchpads = newBatchLoader(func(ids []string) (map[string]interface{}, error) {
+		launchpads, err := service.launchpadRepository.GetAllByIds(ids)
+		values := make(map[string]interface{}, len(launchpads))
+		for i := range launchpads {
+			values[launchpads[i].Id] = &launchpads[i]
+		}
+		return values, err
+	})
+	loaders.destinations = newBatchLoader(func(ids []string) (map[string]interface{}, error) {
+		destinations, err := service.destinationRepository.GetAllByIds(ids)
+		values := make(map[string]interface{}, len(destinations))
+		for i := range destinations {
+			values[destinations[i].Id] = &destinations[i]
+		}
+		return values, err
+	})
+	loaders.launches = newBatchLoader(func(ids []string) (map[string]interface{}, error) {
+		launches, err := service.launchRepository.GetAllByIds(ids)
+		values := make(map[string]interface{}, len(launches))
+		for i := range launches {
+			loaders.launchpads.prime(launches[i].LaunchpadId)
+			loaders.destinations.prime(launches[i].DestinationId)
+			values[launches[i].Id] = &launches[i]
+		}
+		return values, err
+	})
+	return context.WithValue(ctx, graphqlLoadersKey{}, loaders)
+}
+
+func loadersFrom(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+func (l *graphqlLoaders) launchpad(id string) (*launchpadResolver, error) {
+	value, err := l.launchpads.load(id)
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	if launchpad, ok := value.(*Launchpad); ok {
+		return &launchpadResolver{launchpad}, nil
+	}
+	return nil, nil
+}
+
+func (l *graphqlLoaders) destination(id string) (*destinationResolver, error) {
+	value, err := l.destinations.load(id)
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	if destination, ok := value.(*Destination); ok {
+		return &destinationResolver{destination}, nil
+	}
+	return nil, nil
+}
+
+func (l *graphqlLoaders) launch(id string) (*launchResolver, error) {
+	value, err := l.launches.load(id)
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	if launch, ok := value.(*Launch); ok {
+		return &launchResolver{launch}, nil
+	}
+	return nil, nil
+}
+
+// graphqlError converts error to domain error exposed in GraphQL response with its code in extensions,
+// details of internal errors are logged and not exposed to the client
+func graphqlError(err error) error {
+	domainError := asError(err)
+	if domainError.Kind == Internal {
+		log.Printf("internal server error: %s", err)
+	}
+	return domainError
+}
+
+type graphqlResolver struct {
+	service *Service
+}
+
+// Launchpads returns all active launchpads
+func (r *graphqlResolver) Launchpads() ([]*launchpadResolver, error) {
+	launchpads, err := r.service.GetAllLaunchpads()
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	resolvers := make([]*launchpadResolver, 0, len(launchpads))
+	for i := range launchpads {
+		resolvers = append(resolvers, &launchpadResolver{&launchpads[i]})
+	}
+	return resolvers, nil
+}
+
+// Destinations returns all destinations
+func (r *graphqlResolver) Destinations() ([]*destinationResolver, error) {
+	destinations, err := r.service.GetAllDestinations()
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	resolvers := make([]*destinationResolver, 0, len(destinations))
+	for i := range destinations {
+		resolvers = append(resolvers, &destinationResolver{&destinations[i]})
+	}
+	return resolvers, nil
+}
+
+// Launches returns all upcoming launches, their launchpads and destinations are loaded in batch
+func (r *graphqlResolver) Launches(ctx context.Context) ([]*launchResolver, error) {
+	launches, err := r.service.GetAllLaunches()
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	loaders := loadersFrom(ctx)
+	resolvers := make([]*launchResolver, 0, len(launches))
+	for i := range launches {
+		loaders.launchpads.prime(launches[i].LaunchpadId)
+		loaders.destinations.prime(launches[i].DestinationId)
+		resolvers = append(resolvers, &launchResolver{&launches[i]})
+	}
+	return resolvers, nil
+}
+
+// Bookings returns all bookings optionally filtered by status, their launchpads, destinations and launches
+// are loaded in batch
+func (r *graphqlResolver) Bookings(ctx context.Context, args struct{ Status *string }) ([]*bookingResolver, error) {
+	bookings, err := r.service.GetAllBookings()
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	loaders := loadersFrom(ctx)
+	resolvers := make([]*bookingResolver, 0, len(bookings))
+	for i := range bookings {
+		if args.Status != nil && string(bookings[i].Status) != *args.Status {
+			continue
+		}
+		loaders.launchpads.prime(bookings[i].LaunchpadId)
+		loaders.destinations.prime(bookings[i].DestinationId)
+		loaders.launches.prime(bookings[i].LaunchId)
+		resolvers = append(resolvers, &bookingResolver{&bookings[i]})
+	}
+	return resolvers, nil
+}
+
+// Booking returns booking by its id, unknown booking is null
+func (r *graphqlResolver) Booking(args struct{ Id graphql.ID }) (*bookingResolver, error) {
+	if _, err := uuid.Parse(string(args.Id)); err != nil {
+		return nil, newError(CodeRequestIsInvalid, "%s is not a valid id", args.Id)
+	}
+	booking, err := r.service.GetBooking(string(args.Id))
+	if err != nil && asError(err).Code == CodeBookingNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphqlError(err)
+	}
+	return &bookingResolver{booking}, nil
+}
+
+type launchpadResolver struct {
+	launchpad *Launchpad
+}
+
+func (r *launchpadResolver) Id() graphql.ID {
+	return graphql.ID(r.launchpad.Id)
+}
+
+func (r *launchpadResolver) Name() string {
+	return r.launchpad.Name
+}
+
+func (r *launchpadResolver) Status() string {
+	return r.launchpad.Status
+}
+
+func (r *launchpadResolver) Capacity() int32 {
+	return int32(r.launchpad.Capacity)
+}
+
+func (r *launchpadResolver) FareModifierPercent() int32 {
+	return int32(r.launchpad.FareModifierPercent)
+}
+
+type destinationResolver struct {
+	destination *Destination
+}
+
+func (r *destinationResolver) Id() graphql.ID {
+	return graphql.ID(r.destination.Id)
+}
+
+func (r *destinationResolver) Name() string {
+	return r.destination.Name
+}
+
+func (r *destinationResolver) BaseFare() float64 {
+	return float64(r.destination.BaseFare)
+}
+
+func (r *destinationResolver) DistanceKm() float64 {
+	return float64(r.destination.DistanceKm)
+}
+
+func (r *destinationResolver) MissionDays() int32 {
+	return int32(r.destination.MissionDays)
+}
+
+type launchResolver struct {
+	launch *Launch
+}
+
+func (r *launchResolver) Id() graphql.ID {
+	return graphql.ID(r.launch.Id)
+}
+
+func (r *launchResolver) ExternalId() *string {
+	return optionalString(r.launch.ExternalId)
+}
+
+func (r *launchResolver) Date() string {
+	return r.launch.Date.Format(dateLayout)
+}
+
+func (r *launchResolver) Capacity() int32 {
+	return int32(r.launch.Capacity)
+}
+
+func (r *launchResolver) RemainingSeats() int32 {
+	return int32(r.launch.RemainingSeats)
+}
+
+func (r *launchResolver) Launchpad(ctx context.Context) (*launchpadResolver, error) {
+	return loadersFrom(ctx).launchpad(r.launch.LaunchpadId)
+}
+
+func (r *launchResolver) Destination(ctx context.Context) (*destinationResolver, error) {
+	return loadersFrom(ctx).destination(r.launch.DestinationId)
+}
+
+type passengerResolver struct {
+	passenger *Passenger
+}
+
+func (r *passengerResolver) Id() graphql.ID {
+	return graphql.ID(r.passenger.Id)
+}
+
+func (r *passengerResolver) ProfileId() *graphql.ID {
+	if r.passenger.ProfileId == "" {
+		return nil
+	}
+	id := graphql.ID(r.passenger.ProfileId)
+	return &id
+}
+
+func (r *passengerResolver) FirstName() string {
+	return r.passenger.FirstName
+}
+
+func (r *passengerResolver) LastName() string {
+	return r.passenger.LastName
+}
+
+func (r *passengerResolver) Gender() string {
+	return string(r.passenger.Gender)
+}
+
+func (r *passengerResolver) Birthday() string {
+	return r.passenger.Birthday.Format(dateLayout)
+}
+
+type bookingResolver struct {
+	booking *Booking
+}
+
+func (r *bookingResolver) Id() graphql.ID {
+	return graphql.ID(r.booking.Id)
+}
+
+func (r *bookingResolver) Status() string {
+	return string(r.booking.Status)
+}
+
+func (r *bookingResolver) HoldExpiresAt() *string {
+	if r.booking.HoldExpiresAt == nil {
+		return nil
+	}
+	return optionalString(r.booking.HoldExpiresAt.Format(time.RFC3339))
+}
+
+func (r *bookingResolver) LaunchDate() string {
+	return r.booking.LaunchDate.Format(dateLayout)
+}
+
+func (r *bookingResolver) RemainingSeats() int32 {
+	return int32(r.booking.RemainingSeats)
+}
+
+func (r *bookingResolver) Price() float64 {
+	return float64(r.booking.Price)
+}
+
+func (r *bookingResolver) Currency() string {
+	return r.booking.Currency
+}
+
+func (r *bookingResolver) Launchpad(ctx context.Context) (*launchpadResolver, error) {
+	return loadersFrom(ctx).launchpad(r.booking.LaunchpadId)
+}
+
+func (r *bookingResolver) Destination(ctx context.Context) (*destinationResolver, error) {
+	return loadersFrom(ctx).destination(r.booking.DestinationId)
+}
+
+// Launch returns launch of the booking, cancelled bookings have no launch
+func (r *bookingResolver) Launch(ctx context.Context) (*launchResolver, error) {
+	return loadersFrom(ctx).launch(r.booking.LaunchId)
+}
+
+func (r *bookingResolver) Passengers() []*passengerResolver {
+	resolvers := make([]*passengerResolver, 0, len(r.booking.Passengers))
+	for i := range r.booking.Passengers {
+		resolvers = append(resolvers, &passengerResolver{&r.booking.Passengers[i]})
+	}
+	return resolvers
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
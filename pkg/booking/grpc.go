@@ -0,0 +1,286 @@
+package booking
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yosadchyi/space-booking/pkg/booking/bookingpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorDomain is a domain of google.rpc.ErrorInfo details of gRPC errors
+const errorDomain = "space-booking"
+
+// GRPCServer exposes the service over gRPC
+type GRPCServer struct {
+	bookingpb.UnimplementedBookingServiceServer
+	service *Service
+}
+
+// NewGRPCServer creates new gRPC server with booking service and server reflection registered
+func NewGRPCServer(service *Service) *grpc.Server {
+	server := grpc.NewServer(grpc.UnaryInterceptor(logCall))
+	bookingpb.RegisterBookingServiceServer(server, &GRPCServer{service: service})
+	reflection.Register(server)
+	return server
+}
+
+func logCall(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	log.Printf("gRPC %s", info.FullMethod)
+	return handler(ctx, request)
+}
+
+// ListLaunchpads returns all active launchpads
+func (s *GRPCServer) ListLaunchpads(context.Context,
+	*bookingpb.ListLaunchpadsRequest) (*bookingpb.ListLaunchpadsResponse, error) {
+	launchpads, err := s.service.GetAllLaunchpads()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := bookingpb.ListLaunchpadsResponse{Launchpads: make([]*bookingpb.Launchpad, 0, len(launchpads))}
+	for _, launchpad := range launchpads {
+		response.Launchpads = append(response.Launchpads, &bookingpb.Launchpad{
+			Id:                  launchpad.Id,
+			Name:                launchpad.Name,
+			Status:              launchpad.Status,
+			Capacity:            int32(launchpad.Capacity),
+			FareModifierPercent: int32(launchpad.FareModifierPercent),
+		})
+	}
+	return &response, nil
+}
+
+// ListDestinations returns all destinations
+func (s *GRPCServer) ListDestinations(context.Context,
+	*bookingpb.ListDestinationsRequest) (*bookingpb.ListDestinationsResponse, error) {
+	destinations, err := s.service.GetAllDestinations()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := bookingpb.ListDestinationsResponse{
+		Destinations: make([]*bookingpb.Destination, 0, len(destinations)),
+	}
+	for _, destination := range destinations {
+		response.Destinations = append(response.Destinations, &bookingpb.Destination{
+			Id:          destination.Id,
+			Name:        destination.Name,
+			BaseFare:    destination.BaseFare,
+			DistanceKm:  destination.DistanceKm,
+			MissionDays: int32(destination.MissionDays),
+		})
+	}
+	return &response, nil
+}
+
+// ListLaunches returns all upcoming launches along with remaining seats
+func (s *GRPCServer) ListLaunches(context.Context,
+	*bookingpb.ListLaunchesRequest) (*bookingpb.ListLaunchesResponse, error) {
+	launches, err := s.service.GetAllLaunches()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := bookingpb.ListLaunchesResponse{Launches: make([]*bookingpb.Launch, 0, len(launches))}
+	for _, launch := range launches {
+		response.Launches = append(response.Launches, &bookingpb.Launch{
+			Id:             launch.Id,
+			ExternalId:     launch.ExternalId,
+			LaunchpadId:    launch.LaunchpadId,
+			DestinationId:  launch.DestinationId,
+			LaunchDate:     launch.Date.Format(dateLayout),
+			Capacity:       int32(launch.Capacity),
+			RemainingSeats: int32(launch.RemainingSeats),
+		})
+	}
+	return &response, nil
+}
+
+// CreateBooking books the flight for all passengers of the request, booking is paid with given payment token
+func (s *GRPCServer) CreateBooking(ctx context.Context,
+	request *bookingpb.CreateBookingRequest) (*bookingpb.Booking, error) {
+	genders, err := s.service.GetAllGenders()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	v := newValidator(genders)
+	groupRequest := GroupRequest{
+		Passengers:    make([]PassengerRequest, 0, len(request.Passengers)),
+		LaunchpadId:   request.LaunchpadId,
+		DestinationId: request.DestinationId,
+		LaunchDate:    parseDate(v, "launchDate", request.LaunchDate),
+		PaymentToken:  request.PaymentToken,
+		PromoCodes:    request.PromoCodes,
+		ContactEmail:  request.ContactEmail,
+		Locale:        request.Locale,
+	}
+	for i, passenger := range request.Passengers {
+		passengerRequest := PassengerRequest{
+			ProfileId: passenger.ProfileId,
+			FirstName: passenger.FirstName,
+			LastName:  passenger.LastName,
+			Gender:    Gender(passenger.Gender),
+		}
+		if passenger.ProfileId == "" {
+			field := fmt.Sprintf("passengers[%d].birthday", i)
+			passengerRequest.Birthday = parseDate(v, field, passenger.Birthday)
+		}
+		groupRequest.Passengers = append(groupRequest.Passengers, passengerRequest)
+	}
+	v.groupRequest(groupRequest)
+	if err := v.response(); err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.service.AddGroupBooking(grpcActor(ctx), groupRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetBooking(ctx, &bookingpb.GetBookingRequest{Id: response.Id})
+}
+
+// GetBooking returns booking by its id, cancelled bookings are returned as well
+func (s *GRPCServer) GetBooking(_ context.Context, request *bookingpb.GetBookingRequest) (*bookingpb.Booking, error) {
+	if _, err := uuid.Parse(request.Id); err != nil {
+		return nil, grpcError(newError(CodeRequestIsInvalid, "%s is not a valid id", request.Id))
+	}
+	booking, err := s.service.GetBooking(request.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toBookingPb(booking), nil
+}
+
+// ListBookings returns all bookings
+func (s *GRPCServer) ListBookings(context.Context,
+	*bookingpb.ListBookingsRequest) (*bookingpb.ListBookingsResponse, error) {
+	bookings, err := s.service.GetAllBookings()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := bookingpb.ListBookingsResponse{Bookings: make([]*bookingpb.Booking, 0, len(bookings))}
+	for i := range bookings {
+		response.Bookings = append(response.Bookings, toBookingPb(&bookings[i]))
+	}
+	return &response, nil
+}
+
+// CancelBooking cancels booking and refunds part of the paid price according to cancellation policy
+func (s *GRPCServer) CancelBooking(ctx context.Context,
+	request *bookingpb.CancelBookingRequest) (*bookingpb.CancelBookingResponse, error) {
+	if _, err := uuid.Parse(request.Id); err != nil {
+		return nil, grpcError(newError(CodeRequestIsInvalid, "%s is not a valid id", request.Id))
+	}
+	response, err := s.service.DeleteBooking(grpcActor(ctx), request.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &bookingpb.CancelBookingResponse{
+		Id:            response.Id,
+		RefundPercent: int32(response.RefundPercent),
+		RefundAmount:  response.RefundAmount,
+		Currency:      response.Currency,
+	}, nil
+}
+
+func toBookingPb(booking *Booking) *bookingpb.Booking {
+	result := bookingpb.Booking{
+		Id:             booking.Id,
+		Status:         string(booking.Status),
+		LaunchpadId:    booking.LaunchpadId,
+		DestinationId:  booking.DestinationId,
+		LaunchDate:     booking.LaunchDate.Format(dateLayout),
+		LaunchId:       booking.LaunchId,
+		RemainingSeats: int32(booking.RemainingSeats),
+		Price:          booking.Price,
+		Currency:       booking.Currency,
+		ContactEmail:   booking.ContactEmail,
+		Locale:         booking.Locale,
+		Passengers:     make([]*bookingpb.Passenger, 0, len(booking.Passengers)),
+	}
+	if booking.HoldExpiresAt != nil {
+		result.HoldExpiresAt = timestamppb.New(*booking.HoldExpiresAt)
+	}
+	for _, passenger := range booking.Passengers {
+		result.Passengers = append(result.Passengers, &bookingpb.Passenger{
+			Id:        passenger.Id,
+			ProfileId: passenger.ProfileId,
+			FirstName: passenger.FirstName,
+			LastName:  passenger.LastName,
+			Gender:    string(passenger.Gender),
+			Birthday:  passenger.Birthday.Format(dateLayout),
+		})
+	}
+	return &result
+}
+
+// parseDate parses date formatted as YYYY-MM-DD, invalid date is recorded by the validator,
+// empty date is left zero to be reported as missing
+func parseDate(v *validator, field string, value string) Date {
+	if value == "" {
+		return Date{}
+	}
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		v.fail(field, "should be date formatted as YYYY-MM-DD")
+		return Date{}
+	}
+	return Date(date)
+}
+
+// grpcActor returns actor making the call as given in x-actor metadata, used for audit log
+func grpcActor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if actors := md.Get("x-actor"); len(actors) > 0 && actors[0] != "" {
+		return actors[0]
+	}
+	return "anonymous"
+}
+
+// grpcError converts error to gRPC status with code determined by kind of the error, error code is given
+// in ErrorInfo detail and invalid fields in BadRequest detail. Details of internal errors are logged
+// and not exposed to the client
+func grpcError(err error) error {
+	domainError := asError(err)
+	if domainError.Kind == Internal {
+		log.Printf("internal server error: %s", err)
+	}
+	details := []protoiface.MessageV1{&errdetails.ErrorInfo{Reason: domainError.Code, Domain: errorDomain}}
+	if len(domainError.Fields) > 0 {
+		badRequest := errdetails.BadRequest{}
+		for _, field := range domainError.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Reason,
+			})
+		}
+		details = append(details, &badRequest)
+	}
+	st := status.New(grpcCode(domainError.Kind), domainError.Message)
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func grpcCode(kind ErrorKind) codes.Code {
+	switch kind {
+	case NotFound:
+		return codes.NotFound
+	case Conflict:
+		return codes.Aborted
+	case Validation:
+		return codes.InvalidArgument
+	case Unavailable:
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
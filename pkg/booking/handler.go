@@ -1,138 +1,659 @@
 package booking
 
 import (
+	"crypto/subtle"
+	_ "embed"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
 )
 
+// maxBodySize is a maximum size of the request body in bytes
+const maxBodySize = 1 << 20
+
+// eventPollInterval is how often event streams check the event log for new events
+const eventPollInterval = time.Second
+
+// eventHeartbeatInterval is how often comment is written to idle event streams, so connection is kept open
+const eventHeartbeatInterval = 15 * time.Second
+
+// eventBatchSize is a maximum number of events read from the event log at once
+const eventBatchSize = 100
+
+// apiPrefix is a prefix of the current version of the API, routes without prefix are deprecated aliases
+const apiPrefix = "/v1"
+
+// openAPI is OpenAPI specification of the API served at /v1/openapi.json
+//
+//go:embed openapi.json
+var openAPI []byte
+
 // Handler exposes HTTP endpoints
 type Handler struct {
-	service          *Service
-	launchpadPat     *regexp.Regexp
-	destinationPat   *regexp.Regexp
-	bookingPat       *regexp.Regexp
-	deleteBookingPat *regexp.Regexp
+	service *Service
+	router  *router
+	graphql *graphql.Schema
 }
 
 // NewHandler creates new handler ready to handle HTTP requests
 func NewHandler(service *Service) *Handler {
-	return &Handler{
-		service:          service,
-		launchpadPat:     regexp.MustCompile("^/launchpad/?$"),
-		destinationPat:   regexp.MustCompile("^/destination/?$"),
-		bookingPat:       regexp.MustCompile("^/booking/$"),
-		deleteBookingPat: regexp.MustCompile("^/booking/([0-9a-f-]{36})$"),
+	h := &Handler{service: service, graphql: newGraphQLSchema(service)}
+	rt := newRouter(routeNotFound, methodNotAllowed)
+	handle := func(method string, pattern string, handler handlerFunc) {
+		rt.handle(method, apiPrefix+pattern, handler)
+		rt.handle(method, pattern, deprecated(handler))
+	}
+	handle(http.MethodGet, "/launchpad", h.getLaunchpads)
+	handle(http.MethodGet, "/destination", h.getDestinations)
+	handle(http.MethodGet, "/gender", h.getGenders)
+	handle(http.MethodGet, "/launch", h.getLaunches)
+	handle(http.MethodGet, "/booking", h.getBookings)
+	handle(http.MethodPost, "/booking", h.addBooking)
+	handle(http.MethodPost, "/booking/group", h.addGroupBooking)
+	handle(http.MethodGet, "/booking/{id}", h.getBooking)
+	handle(http.MethodDelete, "/booking/{id}", h.deleteBooking)
+	handle(http.MethodPost, "/booking/{id}/status", h.changeBookingStatus)
+	handle(http.MethodGet, "/booking/{id}/history", h.getBookingHistory)
+	handle(http.MethodGet, "/booking/{id}/payment", h.getBookingPayments)
+	handle(http.MethodGet, "/waitlist", h.getWaitlist)
+	handle(http.MethodPost, "/waitlist", h.addToWaitlist)
+	handle(http.MethodPost, "/hold", h.holdSeats)
+	handle(http.MethodPost, "/hold/{id}/confirm", h.confirmHold)
+	handle(http.MethodPost, "/quote", h.quote)
+	handle(http.MethodGet, "/promo/{code}", h.getPromoCode)
+	handle(http.MethodGet, "/passenger", h.getPassengers)
+	handle(http.MethodPost, "/passenger", h.addPassenger)
+	handle(http.MethodGet, "/passenger/duplicates", h.getDuplicatePassengers)
+	handle(http.MethodGet, "/passenger/{id}", h.getPassenger)
+	handle(http.MethodPut, "/passenger/{id}", h.updatePassenger)
+	handle(http.MethodDelete, "/passenger/{id}", h.deletePassenger)
+	handle(http.MethodGet, "/passenger/{id}/booking", h.getPassengerBookings)
+	handle(http.MethodPost, "/passenger/{id}/merge", h.mergePassengers)
+	handle(http.MethodGet, "/passenger/{id}/loyalty", h.getPassengerLoyalty)
+	rt.handle(http.MethodGet, apiPrefix+"/openapi.json", h.getOpenAPI)
+	rt.handle(http.MethodGet, apiPrefix+"/graphql", h.queryGraphQL)
+	rt.handle(http.MethodPost, apiPrefix+"/graphql", h.queryGraphQL)
+	rt.handle(http.MethodGet, apiPrefix+"/events", h.streamEvents)
+	admin := func(method string, pattern string, handler handlerFunc) {
+		rt.handle(method, apiPrefix+"/admin"+pattern, h.admin(handler))
 	}
+	admin(http.MethodPost, "/booking/{id}/no-show", h.markNoShow)
+	admin(http.MethodGet, "/promo", h.getPromoCodes)
+	admin(http.MethodPost, "/promo", h.addPromoCode)
+	admin(http.MethodDelete, "/promo/{code}", h.deactivatePromoCode)
+	admin(http.MethodGet, "/webhook", h.getWebhooks)
+	admin(http.MethodPost, "/webhook", h.addWebhook)
+	admin(http.MethodGet, "/webhook/{id}", h.getWebhook)
+	admin(http.MethodDelete, "/webhook/{id}", h.deactivateWebhook)
+	admin(http.MethodGet, "/webhook/{id}/delivery", h.getWebhookDeliveries)
+	admin(http.MethodPost, "/webhook/{id}/delivery/{deliveryId}/retry", h.retryWebhookDelivery)
+	h.router = rt
+	return h
 }
 
 // ServeHTTP is called on every http request
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.handleGET(w, r)
-	case http.MethodPost:
-		h.handlePOST(w, r)
-	case http.MethodDelete:
-		h.handleDELETE(w, r)
-	default:
-		badRequest(w)
-	}
-}
-
-func (h *Handler) handleGET(w http.ResponseWriter, r *http.Request) {
-	log.Printf("GET %s", r.RequestURI)
-	switch {
-	case h.launchpadPat.MatchString(r.RequestURI):
-		all, err := h.service.GetAllLaunchpads()
-		if err != nil {
-			internalServerError(w, err)
+	log.Printf("%s %s", r.Method, r.RequestURI)
+	h.router.ServeHTTP(w, r)
+}
+
+// admin guards admin route with admin token of the service config given as bearer token in Authorization header,
+// admin routes are disabled if the token is not configured
+func (h *Handler) admin(handler handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p params) {
+		token := h.service.config.AdminToken
+		given := r.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(given, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(given, "Bearer ")), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, newError(CodeUnauthorized, "valid admin token is required"))
 			return
 		}
-		writeJsonResponse(w, http.StatusOK, all)
-	case h.destinationPat.MatchString(r.RequestURI):
-		all, err := h.service.GetAllDestinations()
-		if err != nil {
-			internalServerError(w, err)
-			break
-		}
-		writeJsonResponse(w, http.StatusOK, all)
-	case h.bookingPat.MatchString(r.RequestURI):
-		all, err := h.service.GetAllBookings()
-		if err != nil {
-			internalServerError(w, err)
-			break
+		handler(w, r, p)
+	}
+}
+
+// deprecated marks responses of the route without version prefix as deprecated and links the versioned route,
+// responses are written in the legacy format
+func deprecated(handler handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p params) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiPrefix, r.URL.Path))
+		handler(&legacyResponseWriter{ResponseWriter: w}, r, p)
+	}
+}
+
+func (h *Handler) getOpenAPI(w http.ResponseWriter, _ *http.Request, _ params) {
+	writeResponse(w, http.StatusOK, openAPI)
+}
+
+// queryGraphQL executes GraphQL query given in the body of POST request or in query string of GET request,
+// errors of the query are returned in the response body along with partial data
+func (h *Handler) queryGraphQL(w http.ResponseWriter, r *http.Request, _ params) {
+	request := GraphQLRequest{}
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				invalidJson(w, err)
+				return
+			}
 		}
-		writeJsonResponse(w, http.StatusOK, all)
-	default:
-		http.NotFound(w, r)
+	} else if err := decodeJson(w, r, &request); err != nil {
+		invalidJson(w, err)
+		return
 	}
+	ctx := withGraphQLLoaders(r.Context(), h.service)
+	response := h.graphql.Exec(ctx, request.Query, request.OperationName, request.Variables)
+	writeJsonResponse(w, http.StatusOK, response)
 }
 
-func (h *Handler) handlePOST(w http.ResponseWriter, r *http.Request) {
-	log.Printf("POST %s", r.RequestURI)
-	switch {
-	case h.bookingPat.MatchString(r.RequestURI):
-		request := Request{}
-		err := json.NewDecoder(r.Body).Decode(&request)
+// streamEvents streams events as Server-Sent Events until client disconnects. Stream is resumed after the event
+// given in Last-Event-ID header or lastEventId query parameter, otherwise only new events are streamed.
+// Event log is read as soon as events are relayed from the outbox and is polled in case relay runs elsewhere
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, _ params) {
+	lastId, err := lastEventId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if lastId < 0 {
+		lastId, err = h.service.GetLastEventId()
 		if err != nil {
-			badRequest(w)
-			break
+			writeError(w, err)
+			return
 		}
-		_, err = uuid.Parse(request.DestinationId)
-		if err != nil {
-			badRequest(w)
-			break
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return
+	}
+	flusher.Flush()
+
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	relayed := make(chan struct{}, 1)
+	unsubscribe := h.service.SubscribeEvents(func(*Event) {
+		select {
+		case relayed <- struct{}{}:
+		default:
 		}
-		response, err := h.service.AddBooking(request)
+	})
+	defer unsubscribe()
+	for {
+		events, err := h.service.GetEventsAfter(lastId, eventBatchSize)
 		if err != nil {
-			internalServerError(w, err)
-			break
+			log.Printf("can't read events after %d: %s", lastId, err)
+		}
+		for i := range events {
+			if err := writeEvent(w, &events[i]); err != nil {
+				return
+			}
+			lastId = events[i].Id
 		}
-		if errorResponse, ok := response.(*ErrorResponse); ok {
-			writeJsonResponse(w, http.StatusBadRequest, errorResponse)
-		} else {
-			writeJsonResponse(w, http.StatusOK, response)
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if len(events) == eventBatchSize && r.Context().Err() == nil {
+			continue
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-poll.C:
+		case <-relayed:
 		}
-	default:
-		http.NotFound(w, r)
 	}
 }
 
-func (h *Handler) handleDELETE(w http.ResponseWriter, r *http.Request) {
-	log.Printf("DELETE %s", r.RequestURI)
-	switch {
-	case h.deleteBookingPat.MatchString(r.RequestURI):
-		str := h.deleteBookingPat.FindStringSubmatch(r.RequestURI)
-		id := str[1]
-		_, err := uuid.Parse(id)
-		if err != nil {
-			badRequest(w)
-			break
-		}
-		err = h.service.DeleteBooking(id)
-		if err != nil {
-			internalServerError(w, err)
-		}
-		writeString(w, http.StatusNoContent, "")
-	default:
-		http.NotFound(w, r)
+// lastEventId returns id of the last event seen by the client or -1 if it is not given
+func lastEventId(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	if value == "" {
+		return -1, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, newError(CodeRequestIsInvalid, "%s is not a valid event id", value)
+	}
+	return id, nil
+}
+
+// writeEvent writes event in Server-Sent Events format, event type is given in event field and the whole event
+// is given as JSON data
+func writeEvent(w io.Writer, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+	return err
+}
+
+func (h *Handler) getLaunchpads(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllLaunchpads()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) getDestinations(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllDestinations()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) getGenders(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllGenders()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) getLaunches(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllLaunches()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) getBookings(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllBookings()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) addBooking(w http.ResponseWriter, r *http.Request, _ params) {
+	request := Request{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.request(request) }) {
+		return
+	}
+	response, err := h.service.AddBooking(actor(r), request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) addGroupBooking(w http.ResponseWriter, r *http.Request, _ params) {
+	request := GroupRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.groupRequest(request) }) {
+		return
+	}
+	response, err := h.service.AddGroupBooking(actor(r), request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getBooking(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.GetBooking(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) deleteBooking(w http.ResponseWriter, r *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.DeleteBooking(actor(r), p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) changeBookingStatus(w http.ResponseWriter, r *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	request := StatusRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.statusRequest(request) }) {
+		return
+	}
+	response, err := h.service.ChangeBookingStatus(actor(r), p["id"], request.Status)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) markNoShow(w http.ResponseWriter, r *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.MarkNoShow(actor(r), p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getBookingHistory(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.GetBookingHistory(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getBookingPayments(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.GetBookingPayments(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getWaitlist(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetWaitlist()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) addToWaitlist(w http.ResponseWriter, r *http.Request, _ params) {
+	request := WaitlistRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.waitlistRequest(request) }) {
+		return
+	}
+	response, err := h.service.AddToWaitlist(request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) holdSeats(w http.ResponseWriter, r *http.Request, _ params) {
+	request := GroupRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
 	}
+	if !h.valid(w, func(v *validator) { v.groupRequest(request) }) {
+		return
+	}
+	response, err := h.service.HoldSeats(actor(r), request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) confirmHold(w http.ResponseWriter, r *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	request := ConfirmRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil && err != io.EOF {
+		invalidJson(w, err)
+		return
+	}
+	response, err := h.service.ConfirmHold(actor(r), p["id"], request.PaymentToken)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) quote(w http.ResponseWriter, r *http.Request, _ params) {
+	request := GroupRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.groupRequest(request) }) {
+		return
+	}
+	response, err := h.service.Quote(request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getPromoCodes(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllPromoCodes()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) addPromoCode(w http.ResponseWriter, r *http.Request, _ params) {
+	request := PromoCodeRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.promoCodeRequest(request) }) {
+		return
+	}
+	response, err := h.service.AddPromoCode(request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getPromoCode(w http.ResponseWriter, _ *http.Request, p params) {
+	response, err := h.service.GetPromoCode(p["code"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) deactivatePromoCode(w http.ResponseWriter, _ *http.Request, p params) {
+	response, err := h.service.DeactivatePromoCode(p["code"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getWebhooks(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllWebhooks()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) addWebhook(w http.ResponseWriter, r *http.Request, _ params) {
+	request := WebhookRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.webhookRequest(request) }) {
+		return
+	}
+	response, err := h.service.AddWebhook(request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getWebhook(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.GetWebhook(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) deactivateWebhook(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.DeactivateWebhook(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getWebhookDeliveries(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) {
+		return
+	}
+	response, err := h.service.GetWebhookDeliveries(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) retryWebhookDelivery(w http.ResponseWriter, _ *http.Request, p params) {
+	if !validId(w, p["id"]) || !validId(w, p["deliveryId"]) {
+		return
+	}
+	response, err := h.service.RetryWebhookDelivery(p["id"], p["deliveryId"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getPassengers(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetAllPassengers()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) addPassenger(w http.ResponseWriter, r *http.Request, _ params) {
+	request := PassengerProfileRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.passengerProfileRequest(request) }) {
+		return
+	}
+	response, err := h.service.AddPassenger(request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getDuplicatePassengers(w http.ResponseWriter, _ *http.Request, _ params) {
+	all, err := h.service.GetDuplicatePassengers()
+	writeBookingResponse(w, all, err)
+}
+
+func (h *Handler) getPassenger(w http.ResponseWriter, _ *http.Request, p params) {
+	response, err := h.service.GetPassenger(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) updatePassenger(w http.ResponseWriter, r *http.Request, p params) {
+	request := PassengerProfileRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.passengerProfileRequest(request) }) {
+		return
+	}
+	response, err := h.service.UpdatePassenger(p["id"], request)
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) deletePassenger(w http.ResponseWriter, _ *http.Request, p params) {
+	response, err := h.service.DeletePassenger(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) getPassengerBookings(w http.ResponseWriter, _ *http.Request, p params) {
+	response, err := h.service.GetPassengerBookings(p["id"])
+	writeBookingResponse(w, response, err)
+}
+
+func (h *Handler) mergePassengers(w http.ResponseWriter, r *http.Request, p params) {
+	request := MergeRequest{}
+	err := decodeJson(w, r, &request)
+	if err != nil {
+		invalidJson(w, err)
+		return
+	}
+	if !h.valid(w, func(v *validator) { v.mergeRequest(request) }) {
+		return
+	}
+	response, err := h.service.MergePassengers(p["id"], request)
+	writeBookingResponse(w, response, err)
 }
 
-func badRequest(w http.ResponseWriter) {
-	writeString(w, http.StatusBadRequest, "bad request")
+func (h *Handler) getPassengerLoyalty(w http.ResponseWriter, _ *http.Request, p params) {
+	response, err := h.service.GetPassengerLoyalty(p["id"])
+	writeBookingResponse(w, response, err)
 }
 
-func internalServerError(w http.ResponseWriter, err error) {
-	log.Printf("internal server error: %s", err)
-	writeString(w, http.StatusInternalServerError, "internal server error")
+// valid validates request with given validation, bad request listing invalid fields is written
+// if request is invalid
+func (h *Handler) valid(w http.ResponseWriter, validate func(v *validator)) bool {
+	genders, err := h.service.GetAllGenders()
+	if err != nil {
+		writeError(w, err)
+		return false
+	}
+	v := newValidator(genders)
+	validate(v)
+	if err := v.response(); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
+
+// decodeJson decodes JSON body of the request, unknown fields and bodies larger than maxBodySize are rejected
+func decodeJson(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func invalidJson(w http.ResponseWriter, err error) {
+	writeError(w, newError(CodeRequestIsInvalid, "%s", err))
+}
+
+// validId checks that id given in path is UUID, bad request is written otherwise
+func validId(w http.ResponseWriter, id string) bool {
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, newError(CodeRequestIsInvalid, "%s is not a valid id", id))
+		return false
+	}
+	return true
+}
+
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, newError(CodeRouteNotFound, "%s %s does not exists", r.Method, r.URL.Path))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed []string) {
+	writeError(w, newError(CodeMethodNotAllowed, "method %s is not allowed, allowed methods are %s", r.Method,
+		strings.Join(allowed, ", ")))
+}
+
+// actor returns actor making the request as given in X-Actor header, used for audit log
+func actor(r *http.Request) string {
+	if actor := r.Header.Get("X-Actor"); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// writeBookingResponse writes successful response or error returned by the service
+func writeBookingResponse(w http.ResponseWriter, response interface{}, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, response)
+}
+
+// writeError writes error response with HTTP status determined by kind of the error, details of internal errors
+// are logged and not exposed to the client
+func writeError(w http.ResponseWriter, err error) {
+	domainError := asError(err)
+	if domainError.Kind == Internal {
+		log.Printf("internal server error: %s", err)
+	}
+	writeJsonResponse(w, domainError.Status(), domainError.Response())
 }
 
 func writeJsonResponse(w http.ResponseWriter, status int, data interface{}) {
+	if legacy, ok := w.(*legacyResponseWriter); ok {
+		legacy.value = data
+	}
 	bytes, err := json.Marshal(data)
 	if err != nil {
 		log.Println("can't serialize response")
@@ -141,11 +662,8 @@ func writeJsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	writeResponse(w, status, bytes)
 }
 
-func writeString(w http.ResponseWriter, status int, data string) {
-	writeResponse(w, status, []byte(data))
-}
-
 func writeResponse(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_, err := w.Write(data)
 	if err != nil {
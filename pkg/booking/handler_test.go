@@ -0,0 +1,135 @@
+package booking
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testAdminToken = "admin-token"
+
+// fakeGenders returns genders kept in memory
+type fakeGenders []Gender
+
+func (f fakeGenders) GetAll() ([]Gender, error) {
+	return f, nil
+}
+
+// testHandler creates handler of the service without database, requests reaching repositories other than
+// genders panic
+func testHandler(adminToken string) *Handler {
+	service := &Service{config: Config{AdminToken: adminToken}, genderRepository: fakeGenders{"male", "female"}}
+	return NewHandler(service)
+}
+
+func serve(h *Handler, method string, path string, body string, header http.Header) (*httptest.ResponseRecorder,
+	ErrorResponse) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	response := ErrorResponse{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder, response
+}
+
+func TestAdminRoutesRequireAdminToken(t *testing.T) {
+	cases := []struct {
+		name          string
+		adminToken    string
+		authorization string
+	}{
+		{"no token", testAdminToken, ""},
+		{"wrong token", testAdminToken, "Bearer wrong-token"},
+		{"token without scheme", testAdminToken, testAdminToken},
+		{"admin routes disabled", "", "Bearer "},
+	}
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/admin/booking/0b4c9c5e-52a6-11ed-bdc3-0242ac120002/no-show"},
+		{http.MethodGet, "/v1/admin/promo"},
+		{http.MethodPost, "/v1/admin/promo"},
+		{http.MethodDelete, "/v1/admin/promo/MOONQ3"},
+		{http.MethodGet, "/v1/admin/webhook"},
+		{http.MethodPost, "/v1/admin/webhook/0b4c9c5e-52a6-11ed-bdc3-0242ac120002/delivery/" +
+			"f0f3c6a4-52a5-11ed-bdc3-0242ac120002/retry"},
+	}
+	for _, c := range cases {
+		h := testHandler(c.adminToken)
+		for _, route := range routes {
+			header := http.Header{}
+			if c.authorization != "" {
+				header.Set("Authorization", c.authorization)
+			}
+			recorder, response := serve(h, route.method, route.path, `{}`, header)
+			if recorder.Code != http.StatusUnauthorized || response.Code != CodeUnauthorized {
+				t.Errorf("%s: expected %s %s to be unauthorized, got %d %s", c.name, route.method, route.path,
+					recorder.Code, response.Code)
+			}
+			if recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%s: expected %s %s to ask for bearer token", c.name, route.method, route.path)
+			}
+		}
+	}
+}
+
+func TestPromoCodesAreNotManagedWithoutAdminPrefix(t *testing.T) {
+	h := testHandler(testAdminToken)
+	header := http.Header{"Authorization": {"Bearer " + testAdminToken}}
+	cases := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodGet, "/v1/promo", http.StatusNotFound},
+		{http.MethodPost, "/v1/promo", http.StatusNotFound},
+		{http.MethodPost, "/promo", http.StatusNotFound},
+		{http.MethodDelete, "/v1/promo/MOONQ3", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/promo/MOONQ3", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		if recorder, _ := serve(h, c.method, c.path, `{}`, header); recorder.Code != c.expected {
+			t.Errorf("expected %d for %s %s, got %d", c.expected, c.method, c.path, recorder.Code)
+		}
+	}
+}
+
+func TestInvalidPromoCodeRequestIsRejected(t *testing.T) {
+	h := testHandler(testAdminToken)
+	header := http.Header{"Authorization": {"Bearer " + testAdminToken}}
+	cases := []struct {
+		body  string
+		field string
+	}{
+		{`{"code":"MOON","percentOff":-10}`, "percentOff"},
+		{`{"code":"MOON","percentOff":101}`, "percentOff"},
+		{`{"code":"MOON","amountOff":-100}`, "amountOff"},
+		{`{"code":"MOON","percentOff":10,"amountOff":100}`, "amountOff"},
+		{`{"code":"MOON"}`, "percentOff"},
+		{`{"code":"MOON!","percentOff":10}`, "code"},
+		{`{"code":"MOON","percentOff":10,"validFrom":"2030-02-01T00:00:00Z","validTo":"2030-01-01T00:00:00Z"}`,
+			"validTo"},
+		{`{"code":"MOON","percentOff":10,"launchDateFrom":"2030-02-01","launchDateTo":"2030-01-01"}`,
+			"launchDateTo"},
+		{`{"code":"MOON","percentOff":10,"maxUses":0}`, "maxUses"},
+		{`{"code":"MOON","percentOff":10,"maxUses":-1}`, "maxUses"},
+		{`{"code":"MOON","percentOff":10,"destinationId":"mars"}`, "destinationId"},
+		{`{"code":"MOON","percentOff":10,"launchpadId":"kennedy"}`, "launchpadId"},
+	}
+	for _, c := range cases {
+		recorder, response := serve(h, http.MethodPost, "/v1/admin/promo", c.body, header)
+		if recorder.Code != http.StatusBadRequest || response.Code != CodeValidationFailed {
+			t.Errorf("expected %s to be rejected, got %d %s", c.body, recorder.Code, response.Code)
+			continue
+		}
+		if len(response.Fields) != 1 || response.Fields[0].Field != c.field {
+			t.Errorf("expected %s to be rejected because of %s, got %+v", c.body, c.field, response.Fields)
+		}
+	}
+}
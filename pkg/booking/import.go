@@ -1,6 +1,7 @@
 package booking
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,21 +11,29 @@ import (
 // DataImporter imports the data from external sources
 type DataImporter interface {
 	ImportLaunchpads() error
-	ImportUpcomingSpaceXLaunches() error
+	ImportUpcomingSpaceXLaunches() ([]Launch, error)
 }
 
 type dataImporter struct {
 	client        spacex.Client
 	launchpadRepo LaunchpadRepository
 	launchRepo    LaunchRepository
+	recorder      EventRecorder
 }
 
-// NewDataImporter creates new data importer
-func NewDataImporter(client spacex.Client, launchpadRepo LaunchpadRepository, launchRepo LaunchRepository) DataImporter {
+// EventRecorder records events of changed entities
+type EventRecorder interface {
+	RecordEvent(eventType EventType, entityId string, payload interface{}) error
+}
+
+// NewDataImporter creates new data importer, changes of launchpads and launches are recorded with the recorder
+func NewDataImporter(client spacex.Client, launchpadRepo LaunchpadRepository, launchRepo LaunchRepository,
+	recorder EventRecorder) DataImporter {
 	return &dataImporter{
 		client:        client,
 		launchpadRepo: launchpadRepo,
 		launchRepo:    launchRepo,
+		recorder:      recorder,
 	}
 }
 
@@ -35,7 +44,11 @@ func (d *dataImporter) ImportLaunchpads() error {
 		return err
 	}
 	for _, launchpad := range launchpads {
-		err := d.launchpadRepo.AddOrUpdate(&Launchpad{
+		previous, err := d.launchpadRepo.Get(launchpad.Id)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		err = d.launchpadRepo.AddOrUpdate(&Launchpad{
 			Id:     launchpad.Id,
 			Name:   launchpad.Name,
 			Status: launchpad.Status,
@@ -43,29 +56,88 @@ func (d *dataImporter) ImportLaunchpads() error {
 		if err != nil {
 			return err
 		}
+		if previous == nil || previous.Status == launchpad.Status {
+			continue
+		}
+		updated, err := d.launchpadRepo.Get(launchpad.Id)
+		if err != nil {
+			return err
+		}
+		err = d.event(LaunchpadStatusChanged, updated.Id, updated)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// ImportUpcomingSpaceXLaunches fetches and stores information about upcoming launches, duplicates are ignored
-func (d *dataImporter) ImportUpcomingSpaceXLaunches() error {
+// ImportUpcomingSpaceXLaunches fetches and stores information about upcoming launches, duplicates are ignored.
+// Previously imported launches which are not upcoming anymore or were moved to other date/launchpad are removed
+// and returned, so freed launchpads can be given to someone else
+func (d *dataImporter) ImportUpcomingSpaceXLaunches() ([]Launch, error) {
 	launches, err := d.client.GetUpcomingLaunches()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	imported, err := d.launchRepo.GetAllUpcomingImported()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]Launch, len(imported))
+	for _, launch := range imported {
+		existing[launch.ExternalId] = launch
+	}
+
+	removed := make([]Launch, 0)
 	for _, launch := range launches {
+		date := Date(time.Unix(launch.DateUnix, 0))
+		eventType := LaunchImported
+		if previous, ok := existing[launch.Id]; ok {
+			delete(existing, launch.Id)
+			if previous.LaunchpadId == launch.Launchpad && previous.Date.Format("2006-01-02") == date.Format("2006-01-02") {
+				continue
+			}
+			err := d.launchRepo.Delete(previous.Id)
+			if err != nil {
+				return nil, err
+			}
+			removed = append(removed, previous)
+			eventType = LaunchChanged
+		}
 		newUUID, err := uuid.NewUUID()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = d.launchRepo.Add(&Launch{
+		imported := Launch{
 			Id:          newUUID.String(),
+			ExternalId:  launch.Id,
 			LaunchpadId: launch.Launchpad,
-			Date:        Date(time.Unix(launch.DateUnix, 0)),
-		})
+			Date:        date,
+		}
+		err = d.launchRepo.Add(&imported)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		err = d.event(eventType, imported.Id, &imported)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	for _, launch := range existing {
+		err := d.launchRepo.Delete(launch.Id)
+		if err != nil {
+			return nil, err
+		}
+		removed = append(removed, launch)
+		err = d.event(LaunchRemoved, launch.Id, &launch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// event records change of the imported entity
+func (d *dataImporter) event(eventType EventType, entityId string, payload interface{}) error {
+	return d.recorder.RecordEvent(eventType, entityId, payload)
 }
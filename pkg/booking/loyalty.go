@@ -0,0 +1,55 @@
+package booking
+
+// LoyaltyTier is reached by passengers having at least MinPoints
+type LoyaltyTier struct {
+	Name      string
+	MinPoints int64
+	// PriorityWaitlist puts waitlist entries with passengers of the tier ahead of other entries
+	PriorityWaitlist bool
+}
+
+// LoyaltyPolicy describes how frequent-flyer points are accrued for flown bookings and which tiers they give
+type LoyaltyPolicy struct {
+	// PointsPerMillionKm are accrued per passenger for distance to the destination
+	PointsPerMillionKm int64
+	// MinPoints are accrued per passenger for any flight
+	MinPoints int64
+	// Tiers are ordered by MinPoints ascending, first tier should start at zero points
+	Tiers []LoyaltyTier
+}
+
+// DefaultLoyaltyPolicy returns default loyalty policy
+func DefaultLoyaltyPolicy() LoyaltyPolicy {
+	return LoyaltyPolicy{
+		PointsPerMillionKm: 10,
+		MinPoints:          50,
+		Tiers: []LoyaltyTier{
+			{Name: "blue", MinPoints: 0},
+			{Name: "silver", MinPoints: 2500, PriorityWaitlist: true},
+			{Name: "gold", MinPoints: 10000, PriorityWaitlist: true},
+			{Name: "platinum", MinPoints: 50000, PriorityWaitlist: true},
+		},
+	}
+}
+
+// Points returns points accrued per passenger for the flight to the destination
+func (p LoyaltyPolicy) Points(destination *Destination) int64 {
+	points := destination.DistanceKm * p.PointsPerMillionKm / 1000000
+	if points < p.MinPoints {
+		return p.MinPoints
+	}
+	return points
+}
+
+// Tier returns tier reached with given points and the next tier, nil if there is no next tier
+func (p LoyaltyPolicy) Tier(points int64) (*LoyaltyTier, *LoyaltyTier) {
+	var tier, next *LoyaltyTier
+	for i := range p.Tiers {
+		if points >= p.Tiers[i].MinPoints {
+			tier = &p.Tiers[i]
+		} else if next == nil {
+			next = &p.Tiers[i]
+		}
+	}
+	return tier, next
+}
@@ -1,34 +1,338 @@
 package booking
 
-// Destination launchpad model
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/yosadchyi/space-booking/pkg/payment"
+)
+
+// Destination launchpad model, BaseFare is a fare per passenger in cents
 type Destination struct {
-	Id   string
-	Name string
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	BaseFare    int64  `json:"baseFare"`
+	DistanceKm  int64  `json:"distanceKm"`
+	MissionDays int    `json:"missionDays"`
 }
 
-// Launchpad launchpad model
+// Launchpad launchpad model, FareModifierPercent is applied to the base fare of launches from this launchpad
 type Launchpad struct {
-	Id     string
-	Name   string
-	Status string
+	Id                  string `json:"id"`
+	Name                string `json:"name"`
+	Status              string `json:"status"`
+	Capacity            int    `json:"capacity"`
+	FareModifierPercent int    `json:"fareModifierPercent"`
 }
 
-// Launch launch model
+// Launch launch model, launches imported from SpaceX have no destination and can't be booked
 type Launch struct {
-	Id          string
-	LaunchpadId string
-	Date        Date
+	Id             string `json:"id"`
+	ExternalId     string `json:"externalId"`
+	LaunchpadId    string `json:"launchpadId"`
+	DestinationId  string `json:"destinationId"`
+	Date           Date   `json:"date"`
+	Capacity       int    `json:"capacity"`
+	RemainingSeats int    `json:"remainingSeats"`
+}
+
+// Passenger passenger model, each booking has one or more passengers
+type Passenger struct {
+	Id        string `json:"id"`
+	ProfileId string `json:"profileId"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Gender    Gender `json:"gender"`
+	Birthday  Date   `json:"birthday"`
+}
+
+// PassengerProfile passenger shared across bookings, passengers are identified by name and birthday
+type PassengerProfile struct {
+	Id        string    `json:"id"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	Gender    Gender    `json:"gender"`
+	Birthday  Date      `json:"birthday"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LoyaltyPoints frequent-flyer points accrued by the passenger for the flown booking
+type LoyaltyPoints struct {
+	Id        string    `json:"id"`
+	ProfileId string    `json:"profileId"`
+	BookingId string    `json:"bookingId"`
+	Points    int64     `json:"points"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FlightHistoryItem flown booking of the passenger along with points accrued for it
+type FlightHistoryItem struct {
+	BookingId     string `json:"bookingId"`
+	LaunchDate    Date   `json:"launchDate"`
+	DestinationId string `json:"destinationId"`
+	Destination   string `json:"destination"`
+	DistanceKm    int64  `json:"distanceKm"`
+	Points        int64  `json:"points"`
+}
+
+// BookingStatus status of the booking
+type BookingStatus string
+
+const (
+	HELD      = BookingStatus("held")
+	CONFIRMED = BookingStatus("confirmed")
+	CANCELLED = BookingStatus("cancelled")
+	FLOWN     = BookingStatus("flown")
+	NO_SHOW   = BookingStatus("no_show")
+)
+
+// bookingTransitions lists statuses booking can be moved to from given status,
+// statuses which are not listed are final
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	HELD:      {CONFIRMED, CANCELLED},
+	CONFIRMED: {CANCELLED, FLOWN, NO_SHOW},
+}
+
+// clientStatuses lists statuses clients can move bookings to with ChangeBookingStatus, holds are confirmed only
+// with payment, bookings are marked as no-show by admins and as flown by the service itself, so clients can't
+// collect loyalty points
+var clientStatuses = []BookingStatus{CANCELLED}
+
+// IsSetByClient checks that clients can move bookings to this status
+func (s BookingStatus) IsSetByClient() bool {
+	for _, status := range clientStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
 }
 
-// Booking booking model
+// CanTransitionTo checks that booking can be moved from this status to the given one
+func (s BookingStatus) CanTransitionTo(status BookingStatus) bool {
+	for _, allowed := range bookingTransitions[s] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Booking booking model, held bookings occupy seats only until HoldExpiresAt,
+// cancelled bookings are kept for history but have no launch. Price in cents is agreed at creation time
 type Booking struct {
-	Id            string
-	FirstName     string
-	LastName      string
-	Gender        Gender
-	Birthday      Date
-	LaunchpadId   string
-	DestinationId string
-	LaunchDate    Date
-	LaunchId      string
+	Id             string        `json:"id"`
+	Status         BookingStatus `json:"status"`
+	HoldExpiresAt  *time.Time    `json:"holdExpiresAt"`
+	LaunchpadId    string        `json:"launchpadId"`
+	DestinationId  string        `json:"destinationId"`
+	LaunchDate     Date          `json:"launchDate"`
+	LaunchId       string        `json:"launchId"`
+	RemainingSeats int           `json:"remainingSeats"`
+	Price          int64         `json:"price"`
+	Currency       string        `json:"currency"`
+	ContactEmail   string        `json:"contactEmail"`
+	Locale         string        `json:"locale"`
+	Passengers     []Passenger   `json:"passengers"`
+}
+
+// WaitlistStatus status of the waitlist entry
+type WaitlistStatus string
+
+const (
+	WAITING = WaitlistStatus("waiting")
+	BOOKED  = WaitlistStatus("booked")
+)
+
+// WaitlistEntry waitlist entry model, entry is converted into booking paid with PaymentToken as soon as launchpad
+// becomes available at any date in requested range, OutcomeCode contains result of the last booking attempt
+type WaitlistEntry struct {
+	Id            string             `json:"id"`
+	LaunchpadId   string             `json:"launchpadId"`
+	DestinationId string             `json:"destinationId"`
+	DateFrom      Date               `json:"dateFrom"`
+	DateTo        Date               `json:"dateTo"`
+	Passengers    []PassengerRequest `json:"passengers"`
+	Status        WaitlistStatus     `json:"status"`
+	BookingId     string             `json:"bookingId"`
+	OutcomeCode   string             `json:"outcomeCode"`
+	ProcessedAt   *time.Time         `json:"processedAt"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	PaymentToken  string             `json:"-"`
+	ContactEmail  string             `json:"contactEmail"`
+	Locale        string             `json:"locale"`
+}
+
+// AuditEntry audit log entry, records single change of the booking or of the launch changed along with it.
+// Before is empty for created entities and After is empty for deleted ones
+type AuditEntry struct {
+	Id        int64           `json:"id"`
+	BookingId string          `json:"bookingId"`
+	Entity    string          `json:"entity"`
+	EntityId  string          `json:"entityId"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+// EventType type of the event
+type EventType string
+
+const (
+	BookingCreated         = EventType("booking.created")
+	BookingUpdated         = EventType("booking.updated")
+	BookingCancelled       = EventType("booking.cancelled")
+	LaunchImported         = EventType("launch.imported")
+	LaunchChanged          = EventType("launch.changed")
+	LaunchRemoved          = EventType("launch.removed")
+	LaunchpadStatusChanged = EventType("launchpad.status_changed")
+)
+
+// eventTypes are all types of events
+var eventTypes = []EventType{BookingCreated, BookingUpdated, BookingCancelled, LaunchImported, LaunchChanged,
+	LaunchRemoved, LaunchpadStatusChanged}
+
+// Event event log entry, Payload is a snapshot of the entity after the change or before removal.
+// Events are ordered by Id. AggregateId is set for events relayed from the outbox
+type Event struct {
+	Id          int64           `json:"id"`
+	Type        EventType       `json:"type"`
+	EntityId    string          `json:"entityId"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Payload     json.RawMessage `json:"payload"`
+	AggregateId string          `json:"-"`
+}
+
+// newEvent creates event of the entity with given snapshot as a payload
+func newEvent(eventType EventType, entityId string, payload interface{}) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Type: eventType, EntityId: entityId, Payload: data}, nil
+}
+
+// FreedSeats seats freed on the launchpad at the launch date which are waiting to be offered to the waitlist
+type FreedSeats struct {
+	Id          int64
+	LaunchpadId string
+	LaunchDate  Date
+}
+
+// OutboxEntry event waiting in the outbox to be published to sinks, entries of the same aggregate,
+// e.g. booking, are published in order. PublishedSinks are names of sinks which already accepted the event
+type OutboxEntry struct {
+	Id             int64
+	AggregateId    string
+	Attempts       int
+	PublishedSinks []string
+	Event          Event
+}
+
+// WebhookSubscription subscription of the partner to events of given types, events are posted to Url and signed
+// with Secret. Inactive subscriptions get no new deliveries
+type WebhookSubscription struct {
+	Id         string      `json:"id"`
+	Url        string      `json:"url"`
+	EventTypes []EventType `json:"eventTypes"`
+	Secret     string      `json:"-"`
+	Active     bool        `json:"active"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// DeliveryStatus status of the webhook delivery
+type DeliveryStatus string
+
+const (
+	PENDING   = DeliveryStatus("pending")
+	DELIVERED = DeliveryStatus("delivered")
+	DEAD      = DeliveryStatus("dead")
+)
+
+// WebhookDelivery delivery of the event to the subscription, pending deliveries are attempted at NextAttemptAt,
+// deliveries which failed all attempts are dead and are retried only on request. Log lists all attempts
+type WebhookDelivery struct {
+	Id             string           `json:"id"`
+	SubscriptionId string           `json:"subscriptionId"`
+	EventId        int64            `json:"eventId"`
+	EventType      EventType        `json:"eventType"`
+	Status         DeliveryStatus   `json:"status"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  *time.Time       `json:"nextAttemptAt"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	DeliveredAt    *time.Time       `json:"deliveredAt"`
+	Log            []WebhookAttempt `json:"log"`
+}
+
+// WebhookAttempt single attempt to deliver the event, StatusCode is 0 if no response is received
+type WebhookAttempt struct {
+	Id          int64     `json:"id"`
+	DeliveryId  string    `json:"deliveryId"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+	StatusCode  int       `json:"statusCode"`
+	Error       string    `json:"error"`
+	DurationMs  int64     `json:"durationMs"`
+}
+
+// DueDelivery pending delivery which is due along with its subscription and event
+type DueDelivery struct {
+	Delivery     WebhookDelivery
+	Subscription WebhookSubscription
+	Event        Event
+}
+
+// PaymentTransaction payment transaction made for the booking, Amount is in cents
+type PaymentTransaction struct {
+	Id        string         `json:"id"`
+	BookingId string         `json:"bookingId"`
+	Type      payment.Type   `json:"type"`
+	Reference string         `json:"reference"`
+	Amount    int64          `json:"amount"`
+	Currency  string         `json:"currency"`
+	Status    payment.Status `json:"status"`
+	Message   string         `json:"message"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// PendingRefund refund of the cancelled booking waiting to be made, Percent of the captured price is refunded.
+// Amount is refunded sum in cents, it is set once refund is made
+type PendingRefund struct {
+	BookingId  string
+	Percent    int
+	Amount     int64
+	Attempts   int
+	RefundedAt *time.Time
+}
+
+// PromoCode discount campaign or voucher, discount is either PercentOff of the fare or AmountOff in cents.
+// Optional fields limit when, for which launches and how many times the code can be redeemed,
+// non-stackable code can't be combined with other codes
+type PromoCode struct {
+	Code           string     `json:"code"`
+	Description    string     `json:"description"`
+	PercentOff     int        `json:"percentOff"`
+	AmountOff      int64      `json:"amountOff"`
+	ValidFrom      *time.Time `json:"validFrom"`
+	ValidTo        *time.Time `json:"validTo"`
+	LaunchDateFrom *Date      `json:"launchDateFrom"`
+	LaunchDateTo   *Date      `json:"launchDateTo"`
+	DestinationId  *string    `json:"destinationId"`
+	LaunchpadId    *string    `json:"launchpadId"`
+	MaxUses        *int       `json:"maxUses"`
+	Uses           int        `json:"uses"`
+	Stackable      bool       `json:"stackable"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// PromoRedemption promo code applied to the booking, redemption is released when booking is cancelled
+type PromoRedemption struct {
+	Id        string    `json:"id"`
+	Code      string    `json:"code"`
+	BookingId string    `json:"bookingId"`
+	Amount    int64     `json:"amount"`
+	Released  bool      `json:"released"`
+	CreatedAt time.Time `json:"createdAt"`
 }
@@ -0,0 +1,181 @@
+package booking
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/yosadchyi/space-booking/pkg/notification"
+)
+
+// defaultLocale is a locale of emails of bookings without locale
+const defaultLocale = "en"
+
+// emailKind kind of the email sent to the customer, templates of every locale define subject and body of each kind
+type emailKind string
+
+const (
+	confirmationEmail = emailKind("confirmation")
+	changeEmail       = emailKind("change")
+	cancellationEmail = emailKind("cancellation")
+)
+
+//go:embed templates/email/*.tmpl
+var emailTemplateFiles embed.FS
+
+// emailTemplates templates of emails by locale, locale is a name of the template file
+var emailTemplates = parseEmailTemplates()
+
+func parseEmailTemplates() map[string]*template.Template {
+	files, err := emailTemplateFiles.ReadDir("templates/email")
+	if err != nil {
+		panic(err)
+	}
+	funcs := template.FuncMap{
+		"date": func(date Date) string {
+			return date.Format(dateLayout)
+		},
+		"money": func(cents int64, currency string) string {
+			return fmt.Sprintf("%d.%02d %s", cents/100, cents%100, currency)
+		},
+	}
+	templates := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		locale := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+		templates[locale] = template.Must(template.New(file.Name()).Funcs(funcs).
+			ParseFS(emailTemplateFiles, "templates/email/"+file.Name()))
+	}
+	return templates
+}
+
+// locales returns locales emails can be sent in
+func locales() []string {
+	result := make([]string, 0, len(emailTemplates))
+	for locale := range emailTemplates {
+		result = append(result, locale)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isLocale(locale string) bool {
+	_, ok := emailTemplates[locale]
+	return ok
+}
+
+// emailData data the email templates are executed with
+type emailData struct {
+	Booking     *Booking
+	Launchpad   string
+	Destination string
+}
+
+// renderEmail renders email of given kind about the booking in locale of the booking
+func renderEmail(kind emailKind, data *emailData) (*notification.Message, error) {
+	templates, ok := emailTemplates[data.Booking.Locale]
+	if !ok {
+		templates = emailTemplates[defaultLocale]
+	}
+	subject := bytes.Buffer{}
+	if err := templates.ExecuteTemplate(&subject, string(kind)+".subject", data); err != nil {
+		return nil, err
+	}
+	body := bytes.Buffer{}
+	if err := templates.ExecuteTemplate(&body, string(kind)+".body", data); err != nil {
+		return nil, err
+	}
+	return &notification.Message{To: data.Booking.ContactEmail, Subject: subject.String(), Body: body.String()}, nil
+}
+
+// bookingEmailKind returns kind of the email the customer gets about booking event, empty if customer is not
+// notified about it, e.g. when seats are held. Cancellation email is sent only if booking was confirmed before
+func bookingEmailKind(eventType EventType, status BookingStatus) emailKind {
+	switch {
+	case eventType == BookingCancelled:
+		return cancellationEmail
+	case status == CONFIRMED && (eventType == BookingCreated || eventType == BookingUpdated):
+		return confirmationEmail
+	case status != HELD && eventType == BookingUpdated:
+		return changeEmail
+	}
+	return ""
+}
+
+// notificationSink emails customers about confirmation, changes and cancellation of their bookings relayed
+// from the outbox, bookings without contact email are skipped
+type notificationSink struct {
+	notifier              notification.Notifier
+	launchpadRepository   LaunchpadRepository
+	destinationRepository DestinationRepository
+	auditRepository       AuditRepository
+}
+
+// Publish sends email about the booking event. Messages rejected by the mail server are logged and not retried,
+// temporary failures are retried by the relay until the outbox entry is dead
+func (s *notificationSink) Publish(event *Event) error {
+	if event.Type != BookingCreated && event.Type != BookingUpdated && event.Type != BookingCancelled {
+		return nil
+	}
+	booking := Booking{}
+	if err := json.Unmarshal(event.Payload, &booking); err != nil {
+		return err
+	}
+	kind := bookingEmailKind(event.Type, booking.Status)
+	if booking.ContactEmail == "" || kind == "" {
+		return nil
+	}
+	if kind == cancellationEmail {
+		confirmed, err := s.wasConfirmed(booking.Id)
+		if err != nil || !confirmed {
+			return err
+		}
+	}
+	launchpad, err := s.launchpadRepository.Get(booking.LaunchpadId)
+	if err != nil {
+		return err
+	}
+	destination, err := s.destinationRepository.Get(booking.DestinationId)
+	if err != nil {
+		return err
+	}
+	message, err := renderEmail(kind, &emailData{
+		Booking:     &booking,
+		Launchpad:   launchpad.Name,
+		Destination: destination.Name,
+	})
+	if err != nil {
+		return err
+	}
+	err = s.notifier.Send(message)
+	if notification.IsPermanent(err) {
+		log.Printf("%s email of booking %s is rejected: %s", kind, booking.Id, err)
+		return nil
+	}
+	return err
+}
+
+// wasConfirmed checks whether the cancelled booking was confirmed before cancellation, holds which expired
+// or were not paid are not. Status before cancellation is taken from the audit log
+func (s *notificationSink) wasConfirmed(bookingId string) (bool, error) {
+	entries, err := s.auditRepository.GetAllForBooking(bookingId)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Entity != "booking" || entry.Action != "cancel" {
+			continue
+		}
+		before := Booking{}
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return false, err
+		}
+		return before.Status == CONFIRMED, nil
+	}
+	return false, nil
+}
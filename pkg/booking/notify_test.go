@@ -0,0 +1,99 @@
+package booking
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailsAreRenderedInEveryLocale(t *testing.T) {
+	booking := Booking{
+		Id:           "0b4c9c5e-52a6-11ed-bdc3-0242ac120002",
+		Status:       FLOWN,
+		LaunchDate:   Date(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)),
+		Price:        1234567,
+		Currency:     "USD",
+		ContactEmail: "traveller@example.com",
+		Passengers:   []Passenger{{FirstName: "Yuri", LastName: "Gagarin"}},
+	}
+	data := emailData{Booking: &booking, Launchpad: "Kennedy Space Center", Destination: "Mars"}
+	for _, locale := range locales() {
+		booking.Locale = locale
+		for _, kind := range []emailKind{confirmationEmail, changeEmail, cancellationEmail} {
+			message, err := renderEmail(kind, &data)
+			if err != nil {
+				t.Fatalf("can't render %s email in %s: %s", kind, locale, err)
+			}
+			if message.To != booking.ContactEmail || !strings.Contains(message.Subject, booking.Id) {
+				t.Errorf("%s email in %s has unexpected recipient %q or subject %q", kind, locale, message.To,
+					message.Subject)
+			}
+			for _, expected := range []string{booking.Id, "Kennedy Space Center", "Mars", "2030-01-02", "Gagarin"} {
+				if !strings.Contains(message.Body, expected) {
+					t.Errorf("%s email in %s does not contain %q:\n%s", kind, locale, expected, message.Body)
+				}
+			}
+		}
+	}
+}
+
+func TestBookingEmailKind(t *testing.T) {
+	cases := []struct {
+		eventType EventType
+		status    BookingStatus
+		expected  emailKind
+	}{
+		{BookingCreated, HELD, ""},
+		{BookingCreated, CONFIRMED, confirmationEmail},
+		{BookingUpdated, CONFIRMED, confirmationEmail},
+		{BookingUpdated, FLOWN, changeEmail},
+		{BookingUpdated, NO_SHOW, changeEmail},
+		{BookingCancelled, CANCELLED, cancellationEmail},
+		{LaunchRemoved, "", ""},
+	}
+	for _, c := range cases {
+		if actual := bookingEmailKind(c.eventType, c.status); actual != c.expected {
+			t.Errorf("expected %q email for %s of %s booking, got %q", c.expected, c.eventType, c.status, actual)
+		}
+	}
+}
+
+// fakeAudit returns audit entries of the booking kept in memory
+type fakeAudit struct {
+	entries []AuditEntry
+}
+
+func (f *fakeAudit) AddTx(*sql.Tx, *AuditEntry) error {
+	return nil
+}
+
+func (f *fakeAudit) GetAllForBooking(string) ([]AuditEntry, error) {
+	return f.entries, nil
+}
+
+func TestCancellationEmailIsSentOnlyForConfirmedBookings(t *testing.T) {
+	cases := []struct {
+		before   BookingStatus
+		expected bool
+	}{
+		{HELD, false},
+		{CONFIRMED, true},
+	}
+	for _, c := range cases {
+		before, _ := json.Marshal(Booking{Id: "booking-1", Status: c.before})
+		after, _ := json.Marshal(Booking{Id: "booking-1", Status: CANCELLED})
+		sink := notificationSink{auditRepository: &fakeAudit{entries: []AuditEntry{
+			{Entity: "booking", Action: "create", After: before},
+			{Entity: "booking", Action: "cancel", Before: before, After: after},
+		}}}
+		confirmed, err := sink.wasConfirmed("booking-1")
+		if err != nil {
+			t.Fatalf("can't check %s booking: %s", c.before, err)
+		}
+		if confirmed != c.expected {
+			t.Errorf("expected cancellation of %s booking to be emailed: %t, got %t", c.before, c.expected, confirmed)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package booking
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// openAPIDocument is a part of OpenAPI document checked against the handler
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPISchema struct {
+	Ref        string                     `json:"$ref"`
+	AllOf      []openAPISchema            `json:"allOf"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+// openAPITypes are Go types described by component schemas of the OpenAPI document
+var openAPITypes = map[string]interface{}{
+	"Request":                 Request{},
+	"PassengerRequest":        PassengerRequest{},
+	"GroupRequest":            GroupRequest{},
+	"WaitlistRequest":         WaitlistRequest{},
+	"PassengerProfileRequest": PassengerProfileRequest{},
+	"MergeRequest":            MergeRequest{},
+	"ConfirmRequest":          ConfirmRequest{},
+	"GraphQLRequest":          GraphQLRequest{},
+	"StatusRequest":           StatusRequest{},
+	"PromoCodeRequest":        PromoCodeRequest{},
+	"SuccessResponse":         SuccessResponse{},
+	"HoldResponse":            HoldResponse{},
+	"QuoteItem":               QuoteItem{},
+	"Quote":                   Quote{},
+	"CancellationResponse":    CancellationResponse{},
+	"ErrorResponse":           ErrorResponse{},
+	"FieldError":              FieldError{},
+	"Launchpad":               Launchpad{},
+	"Destination":             Destination{},
+	"Launch":                  Launch{},
+	"Passenger":               Passenger{},
+	"PassengerProfile":        PassengerProfile{},
+	"Booking":                 Booking{},
+	"WaitlistEntry":           WaitlistEntry{},
+	"AuditEntry":              AuditEntry{},
+	"Event":                   Event{},
+	"WebhookRequest":          WebhookRequest{},
+	"WebhookSubscription":     WebhookSubscription{},
+	"WebhookDelivery":         WebhookDelivery{},
+	"WebhookAttempt":          WebhookAttempt{},
+	"PaymentTransaction":      PaymentTransaction{},
+	"PromoCode":               PromoCode{},
+	"PromoCodeReport":         PromoCodeReport{},
+	"PromoCodeResponse":       PromoCodeResponse{},
+	"PromoRedemption":         PromoRedemption{},
+	"LoyaltyResponse":         LoyaltyResponse{},
+	"FlightHistoryItem":       FlightHistoryItem{},
+}
+
+func loadOpenAPI(t *testing.T) *openAPIDocument {
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPI, &doc); err != nil {
+		t.Fatalf("can't parse OpenAPI document: %s", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("expected OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+	return &doc
+}
+
+func TestOpenAPIDescribesAllRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	documented := make(map[string]bool)
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routes := make(map[string]bool)
+	for _, route := range NewHandler(nil).router.routes {
+		path := "/" + strings.Join(route.segments, "/")
+		if !strings.HasPrefix(path, apiPrefix+"/") {
+			if !documented[route.method+" "+apiPrefix+path] {
+				t.Errorf("deprecated route %s %s has no versioned counterpart", route.method, path)
+			}
+			continue
+		}
+		routes[route.method+" "+path] = true
+		if !documented[route.method+" "+path] {
+			t.Errorf("route %s %s is not documented", route.method, path)
+		}
+	}
+	for operation := range documented {
+		if !routes[operation] {
+			t.Errorf("documented operation %s is not routed", operation)
+		}
+	}
+}
+
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	for name := range doc.Components.Schemas {
+		if _, ok := openAPITypes[name]; !ok {
+			t.Errorf("schema %s does not describe any type", name)
+		}
+	}
+	for name, value := range openAPITypes {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("type %s is not documented", name)
+			continue
+		}
+		documented := schemaProperties(doc, schema)
+		actual := jsonFields(reflect.TypeOf(value))
+		sort.Strings(documented)
+		sort.Strings(actual)
+		if !reflect.DeepEqual(documented, actual) {
+			t.Errorf("schema %s has properties %v, type has fields %v", name, documented, actual)
+		}
+	}
+}
+
+func TestOpenAPIIsServed(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON content type, got %q", w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != string(openAPI) {
+		t.Error("served document differs from the specification")
+	}
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/booking/not-an-id", nil))
+	if w.Header().Get("Deprecation") != "true" {
+		t.Error("expected route without version prefix to be deprecated")
+	}
+	if link := w.Header().Get("Link"); link != `</v1/booking/not-an-id>; rel="successor-version"` {
+		t.Errorf("unexpected successor link %q", link)
+	}
+
+	w = httptest.NewRecorder()
+	NewHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/booking/not-an-id", nil))
+	if w.Header().Get("Deprecation") != "" {
+		t.Error("expected versioned route not to be deprecated")
+	}
+}
+
+// schemaProperties returns names of the properties of the schema including properties of schemas it is composed of
+func schemaProperties(doc *openAPIDocument, schema openAPISchema) []string {
+	properties := make([]string, 0, len(schema.Properties))
+	for property := range schema.Properties {
+		properties = append(properties, property)
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		properties = append(properties, schemaProperties(doc, doc.Components.Schemas[name])...)
+	}
+	for _, part := range schema.AllOf {
+		properties = append(properties, schemaProperties(doc, part)...)
+	}
+	return properties
+}
+
+// jsonFields returns names of the fields of the struct as they are serialized by encoding/json
+func jsonFields(t reflect.Type) []string {
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
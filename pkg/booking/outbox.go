@@ -0,0 +1,172 @@
+package booking
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// outboxBatchSize is a maximum number of outbox entries claimed at once
+	outboxBatchSize = 100
+	// outboxLease is time claimed entries are not claimed again while being published
+	outboxLease = time.Minute
+	// outboxMaxBackoff limits delay before the entry which failed to be published is retried
+	outboxMaxBackoff = time.Minute
+	// outboxMaxAttempts is a number of attempts to publish the entry after which it is dead
+	outboxMaxAttempts = 20
+)
+
+// EventSink receives events relayed from the outbox. Events are delivered at least once: event is published again
+// to the sink which fails or if relay stops before the result is recorded, so sinks should be idempotent, e.g.
+// by event id. Events of the same aggregate are published in order, the next one is published only after
+// the previous one is accepted by all sinks or is dead
+type EventSink interface {
+	Publish(event *Event) error
+}
+
+// LogSink writes relayed events to the log
+type LogSink struct{}
+
+// Publish logs the event
+func (LogSink) Publish(event *Event) error {
+	log.Printf("event %d %s of %s", event.Id, event.Type, event.EntityId)
+	return nil
+}
+
+// webhookSink adds deliveries of relayed events to webhook subscriptions, deliveries of the event are added once
+type webhookSink struct {
+	webhookRepository WebhookRepository
+}
+
+// Publish adds deliveries of the event to all active subscriptions to its type
+func (s *webhookSink) Publish(event *Event) error {
+	return s.webhookRepository.AddDeliveries(event)
+}
+
+// eventSubscribers notifies in-process subscribers about relayed events
+type eventSubscribers struct {
+	mutex       sync.Mutex
+	nextId      int
+	subscribers map[int]func(event *Event)
+}
+
+func newEventSubscribers() *eventSubscribers {
+	return &eventSubscribers{subscribers: make(map[int]func(event *Event))}
+}
+
+// subscribe adds subscriber and returns function removing it
+func (e *eventSubscribers) subscribe(subscriber func(event *Event)) func() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	id := e.nextId
+	e.nextId++
+	e.subscribers[id] = subscriber
+	return func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		delete(e.subscribers, id)
+	}
+}
+
+// Publish calls every subscriber with the event
+func (e *eventSubscribers) Publish(event *Event) error {
+	e.mutex.Lock()
+	subscribers := make([]func(event *Event), 0, len(e.subscribers))
+	for _, subscriber := range e.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	e.mutex.Unlock()
+	for _, subscriber := range subscribers {
+		subscriber(event)
+	}
+	return nil
+}
+
+// SubscribeEvents calls subscriber with every event relayed from the outbox until returned function is called.
+// Subscriber is called from the relay, so it should not block
+func (s *Service) SubscribeEvents(subscriber func(event *Event)) func() {
+	return s.subscribers.subscribe(subscriber)
+}
+
+// RelayOutbox publishes events of the outbox to sinks until no entry is due. Entries are claimed for outboxLease,
+// no transaction is kept open while sinks are called. Entry which fails to be published is retried with exponential
+// backoff only for sinks which have not accepted it, later entries of its aggregate wait until it is published
+// or is dead after outboxMaxAttempts
+func (s *Service) RelayOutbox() error {
+	for {
+		entries, err := s.outboxRepository.Claim(outboxBatchSize, outboxLease)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			if err := s.relay(&entries[i]); err != nil {
+				return err
+			}
+		}
+		if len(entries) < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// relay publishes entry to every sink which has not accepted it yet and records the result,
+// failure of one sink does not keep the event from other sinks
+func (s *Service) relay(entry *OutboxEntry) error {
+	published := make(map[string]bool, len(entry.PublishedSinks))
+	for _, name := range entry.PublishedSinks {
+		published[name] = true
+	}
+	failures := make([]string, 0)
+	for i, name := range sinkNames(s.eventSinks) {
+		if published[name] {
+			continue
+		}
+		if err := s.eventSinks[i].Publish(&entry.Event); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		entry.PublishedSinks = append(entry.PublishedSinks, name)
+	}
+	if len(failures) == 0 {
+		return s.outboxRepository.MarkPublished(entry.Id)
+	}
+	reason := strings.Join(failures, "; ")
+	attempts := entry.Attempts + 1
+	if attempts >= outboxMaxAttempts {
+		log.Printf("event %d of %s is dead after %d attempts: %s", entry.Event.Id, entry.AggregateId, attempts, reason)
+		return s.outboxRepository.MarkDead(entry.Id, entry.PublishedSinks, reason)
+	}
+	log.Printf("can't publish event %d of %s: %s", entry.Event.Id, entry.AggregateId, reason)
+	return s.outboxRepository.MarkFailed(entry.Id, entry.PublishedSinks, reason, outboxBackoff(attempts))
+}
+
+// sinkNames returns names sinks are recorded with in the outbox: type of the sink, numbered if there are
+// several sinks of the same type
+func sinkNames(sinks []EventSink) []string {
+	names := make([]string, len(sinks))
+	seen := make(map[string]int, len(sinks))
+	for i, sink := range sinks {
+		name := fmt.Sprintf("%T", sink)
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// outboxBackoff returns delay before the next attempt to publish the entry after given number of failed attempts
+func outboxBackoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
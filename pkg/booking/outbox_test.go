@@ -0,0 +1,139 @@
+package booking
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeOutbox keeps outbox entries in memory and claims them the same way as the repository, now is a clock
+// of the outbox moved by the test
+type fakeOutbox struct {
+	now     time.Time
+	entries []*fakeOutboxEntry
+}
+
+type fakeOutboxEntry struct {
+	OutboxEntry
+	nextAttemptAt time.Time
+	published     bool
+	dead          bool
+}
+
+func (f *fakeOutbox) add(aggregateId string, eventId int64) {
+	f.entries = append(f.entries, &fakeOutboxEntry{OutboxEntry: OutboxEntry{
+		Id:          int64(len(f.entries) + 1),
+		AggregateId: aggregateId,
+		Event:       Event{Id: eventId, Type: BookingUpdated, EntityId: aggregateId, AggregateId: aggregateId},
+	}, nextAttemptAt: f.now})
+}
+
+func (f *fakeOutbox) get(id int64) *fakeOutboxEntry {
+	return f.entries[id-1]
+}
+
+func (f *fakeOutbox) AddTx(*sql.Tx, string, *Event) error {
+	return nil
+}
+
+func (f *fakeOutbox) Claim(limit int, lease time.Duration) ([]OutboxEntry, error) {
+	claimed := make([]OutboxEntry, 0)
+	blocked := make(map[string]bool)
+	for _, entry := range f.entries {
+		if entry.published || entry.dead {
+			continue
+		}
+		if !blocked[entry.AggregateId] && !entry.nextAttemptAt.After(f.now) && len(claimed) < limit {
+			entry.nextAttemptAt = f.now.Add(lease)
+			claimed = append(claimed, entry.OutboxEntry)
+		}
+		blocked[entry.AggregateId] = true
+	}
+	return claimed, nil
+}
+
+func (f *fakeOutbox) MarkPublished(id int64) error {
+	f.get(id).published = true
+	return nil
+}
+
+func (f *fakeOutbox) MarkFailed(id int64, publishedSinks []string, _ string, retryIn time.Duration) error {
+	entry := f.get(id)
+	entry.Attempts++
+	entry.PublishedSinks = publishedSinks
+	entry.nextAttemptAt = f.now.Add(retryIn)
+	return nil
+}
+
+func (f *fakeOutbox) MarkDead(id int64, publishedSinks []string, _ string) error {
+	entry := f.get(id)
+	entry.Attempts++
+	entry.PublishedSinks = publishedSinks
+	entry.dead = true
+	return nil
+}
+
+// recordingSink records ids of published events
+type recordingSink struct {
+	published []int64
+}
+
+func (s *recordingSink) Publish(event *Event) error {
+	s.published = append(s.published, event.Id)
+	return nil
+}
+
+// poisonedSink fails to publish event with given id
+type poisonedSink struct {
+	eventId int64
+}
+
+func (s poisonedSink) Publish(event *Event) error {
+	if event.Id == s.eventId {
+		return errors.New("mailbox is temporarily unavailable")
+	}
+	return nil
+}
+
+func TestPoisonedOutboxEntryStopsBlockingItsAggregate(t *testing.T) {
+	outbox := &fakeOutbox{now: time.Now()}
+	outbox.add("booking-1", 1)
+	outbox.add("booking-1", 2)
+	outbox.add("booking-2", 3)
+	recording := &recordingSink{}
+	service := &Service{outboxRepository: outbox, eventSinks: []EventSink{poisonedSink{eventId: 1}, recording}}
+
+	if err := service.RelayOutbox(); err != nil {
+		t.Fatalf("can't relay outbox: %s", err)
+	}
+	if outbox.get(2).published || !outbox.get(3).published {
+		t.Fatalf("expected event 2 to wait for event 1 and event 3 of other booking to be published")
+	}
+	for i := 1; i < outboxMaxAttempts; i++ {
+		outbox.now = outbox.now.Add(outboxMaxBackoff)
+		if err := service.RelayOutbox(); err != nil {
+			t.Fatalf("can't relay outbox: %s", err)
+		}
+	}
+
+	if entry := outbox.get(1); !entry.dead || entry.Attempts != outboxMaxAttempts {
+		t.Errorf("expected event 1 to be dead after %d attempts, got %d attempts", outboxMaxAttempts, entry.Attempts)
+	}
+	if err := service.RelayOutbox(); err != nil {
+		t.Fatalf("can't relay outbox: %s", err)
+	}
+	if !outbox.get(2).published {
+		t.Error("expected event 2 to be published after event 1 is dead")
+	}
+	expected := []int64{1, 3, 2}
+	if len(recording.published) != len(expected) {
+		t.Fatalf("expected events %v to be published to healthy sink once, got %v", expected, recording.published)
+	}
+	for i, id := range expected {
+		if recording.published[i] != id {
+			t.Errorf("expected events %v to be published to healthy sink once, got %v", expected, recording.published)
+			break
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package booking
+
+import (
+	"fmt"
+	"time"
+)
+
+// LeadTimeRule modifies fare of launches booked at least MinDays before the launch date
+type LeadTimeRule struct {
+	MinDays int
+	Percent int
+}
+
+// AgeRule modifies fare of passengers which are at least MinAge and less than MaxAge years old at launch date,
+// zero MaxAge means no upper limit
+type AgeRule struct {
+	Name    string
+	MinAge  int
+	MaxAge  int
+	Percent int
+}
+
+// PricingPolicy describes modifiers applied to the base fare of the destination, all modifiers are percents
+// of the base fare and are summed up
+type PricingPolicy struct {
+	Currency string
+	// LeadTime rules are checked in order, first matching rule is applied
+	LeadTime []LeadTimeRule
+	// DayOfWeek modifiers are applied by day of week of the launch date
+	DayOfWeek map[time.Weekday]int
+	// Age rules are checked in order, first matching rule is applied
+	Age []AgeRule
+}
+
+// DefaultPricingPolicy returns default pricing policy
+func DefaultPricingPolicy() PricingPolicy {
+	return PricingPolicy{
+		Currency: "USD",
+		LeadTime: []LeadTimeRule{
+			{MinDays: 180, Percent: -15},
+			{MinDays: 60, Percent: 0},
+			{MinDays: 14, Percent: 10},
+			{MinDays: 0, Percent: 25},
+		},
+		DayOfWeek: map[time.Weekday]int{
+			time.Friday:   5,
+			time.Saturday: 10,
+			time.Sunday:   10,
+		},
+		Age: []AgeRule{
+			{Name: "child", MinAge: 0, MaxAge: 12, Percent: -50},
+			{Name: "youth", MinAge: 12, MaxAge: 18, Percent: -25},
+			{Name: "senior", MinAge: 65, MaxAge: 0, Percent: -10},
+		},
+	}
+}
+
+// FareCalculator calculates itemized price of the booking
+type FareCalculator interface {
+	Quote(request GroupRequest) (*Quote, error)
+}
+
+type fareCalculator struct {
+	policy          PricingPolicy
+	destinationRepo DestinationRepository
+	launchpadRepo   LaunchpadRepository
+}
+
+// NewFareCalculator creates new fare calculator
+func NewFareCalculator(policy PricingPolicy, destinationRepo DestinationRepository,
+	launchpadRepo LaunchpadRepository) FareCalculator {
+	return &fareCalculator{
+		policy:          policy,
+		destinationRepo: destinationRepo,
+		launchpadRepo:   launchpadRepo,
+	}
+}
+
+// Quote calculates price of the request, destination and launchpad are expected to exist
+func (f *fareCalculator) Quote(request GroupRequest) (*Quote, error) {
+	destination, err := f.destinationRepo.Get(request.DestinationId)
+	if err != nil {
+		return nil, err
+	}
+	launchpad, err := f.launchpadRepo.Get(request.LaunchpadId)
+	if err != nil {
+		return nil, err
+	}
+	launchDate := time.Time(request.LaunchDate)
+	leadTime := f.leadTimePercent(launchDate)
+	dayOfWeek := f.policy.DayOfWeek[launchDate.Weekday()]
+
+	quote := Quote{
+		Currency: f.policy.Currency,
+		Items:    make([]QuoteItem, 0, len(request.Passengers)),
+	}
+	for i, passenger := range request.Passengers {
+		add := func(description string, amount int64) {
+			quote.Items = append(quote.Items, QuoteItem{
+				Passenger:   i,
+				Description: description,
+				Amount:      amount,
+			})
+			quote.Total += amount
+		}
+		percentOf := func(percent int) int64 {
+			return destination.BaseFare * int64(percent) / 100
+		}
+
+		add(fmt.Sprintf("base fare to %s", destination.Name), destination.BaseFare)
+		if launchpad.FareModifierPercent != 0 {
+			add(fmt.Sprintf("launchpad %s %+d%%", launchpad.Name, launchpad.FareModifierPercent),
+				percentOf(launchpad.FareModifierPercent))
+		}
+		if leadTime != 0 {
+			add(fmt.Sprintf("lead time %+d%%", leadTime), percentOf(leadTime))
+		}
+		if dayOfWeek != 0 {
+			add(fmt.Sprintf("%s launch %+d%%", launchDate.Weekday(), dayOfWeek), percentOf(dayOfWeek))
+		}
+		if rule := f.ageRule(time.Time(passenger.Birthday), launchDate); rule != nil && rule.Percent != 0 {
+			add(fmt.Sprintf("%s fare %+d%%", rule.Name, rule.Percent), percentOf(rule.Percent))
+		}
+	}
+	return &quote, nil
+}
+
+func (f *fareCalculator) leadTimePercent(launchDate time.Time) int {
+	days := int(time.Until(launchDate).Hours() / 24)
+	for _, rule := range f.policy.LeadTime {
+		if days >= rule.MinDays {
+			return rule.Percent
+		}
+	}
+	return 0
+}
+
+func (f *fareCalculator) ageRule(birthday time.Time, launchDate time.Time) *AgeRule {
+	age := ageAt(birthday, launchDate)
+	for i, rule := range f.policy.Age {
+		if age >= rule.MinAge && (rule.MaxAge == 0 || age < rule.MaxAge) {
+			return &f.policy.Age[i]
+		}
+	}
+	return nil
+}
+
+// ageAt returns age in full years at given date
+func ageAt(birthday time.Time, date time.Time) int {
+	age := date.Year() - birthday.Year()
+	if date.Month() < birthday.Month() || (date.Month() == birthday.Month() && date.Day() < birthday.Day()) {
+		age--
+	}
+	return age
+}
@@ -0,0 +1,105 @@
+package booking
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var promoCodePattern = regexp.MustCompile("^[A-Z0-9_-]{1,32}$")
+
+// normalizePromoCode converts promo code to its canonical form, codes are case-insensitive
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validatePromoCode checks that promo code definition is consistent
+func validatePromoCode(promo *PromoCode) error {
+	invalid := func(message string) error {
+		return newError(CodePromoCodeIsInvalid, "%s", message)
+	}
+	switch {
+	case !promoCodePattern.MatchString(promo.Code):
+		return invalid("promo code should be 1 to 32 letters, digits, underscores or dashes")
+	case promo.PercentOff < 0 || promo.PercentOff > 100:
+		return invalid("percent off should be between 0 and 100")
+	case promo.AmountOff < 0:
+		return invalid("amount off can't be negative")
+	case (promo.PercentOff == 0) == (promo.AmountOff == 0):
+		return invalid("either percent off or amount off should be given")
+	case promo.ValidFrom != nil && promo.ValidTo != nil && !promo.ValidTo.After(*promo.ValidFrom):
+		return invalid("validity window is empty")
+	case promo.LaunchDateFrom != nil && promo.LaunchDateTo != nil &&
+		time.Time(*promo.LaunchDateTo).Before(time.Time(*promo.LaunchDateFrom)):
+		return invalid("launch date window is empty")
+	case promo.MaxUses != nil && *promo.MaxUses <= 0:
+		return invalid("max uses should be positive")
+	}
+	return nil
+}
+
+// applyPromoCodes adds discounts of the promo codes to the quote and returns redemptions to be recorded
+// for the booking. Percent discounts are calculated from the price before any discount, total discount
+// never exceeds the price
+func applyPromoCodes(quote *Quote, request GroupRequest, promos []PromoCode, now time.Time) ([]PromoRedemption,
+	error) {
+	seen := make(map[string]bool, len(promos))
+	for i := range promos {
+		if seen[promos[i].Code] {
+			return nil, newError(CodePromoCodesNotStackable, "promo code %s is given more than once", promos[i].Code)
+		}
+		seen[promos[i].Code] = true
+		if !promos[i].Stackable && len(promos) > 1 {
+			return nil, newError(CodePromoCodesNotStackable, "promo code %s can't be combined with other codes", promos[i].Code)
+		}
+		if err := promoError(&promos[i], request, now); err != nil {
+			return nil, err
+		}
+	}
+
+	price := quote.Total
+	redemptions := make([]PromoRedemption, 0, len(promos))
+	for _, promo := range promos {
+		amount := promo.AmountOff
+		description := fmt.Sprintf("promo code %s", promo.Code)
+		if promo.PercentOff != 0 {
+			amount = price * int64(promo.PercentOff) / 100
+			description = fmt.Sprintf("promo code %s -%d%%", promo.Code, promo.PercentOff)
+		}
+		if amount > quote.Total {
+			amount = quote.Total
+		}
+		quote.Items = append(quote.Items, QuoteItem{
+			Passenger:   -1,
+			Description: description,
+			Amount:      -amount,
+		})
+		quote.Total -= amount
+		redemptions = append(redemptions, PromoRedemption{
+			Code:   promo.Code,
+			Amount: amount,
+		})
+	}
+	return redemptions, nil
+}
+
+// promoError checks that promo code can be applied to the request at given time
+func promoError(promo *PromoCode, request GroupRequest, now time.Time) error {
+	launchDate := time.Time(request.LaunchDate)
+	switch {
+	case !promo.Active:
+		return newError(CodePromoCodeIsInvalid, "promo code %s is not active", promo.Code)
+	case promo.ValidFrom != nil && now.Before(*promo.ValidFrom),
+		promo.ValidTo != nil && !now.Before(*promo.ValidTo):
+		return newError(CodePromoCodeIsExpired, "promo code %s can't be used at this time", promo.Code)
+	case promo.LaunchDateFrom != nil && launchDate.Before(time.Time(*promo.LaunchDateFrom)),
+		promo.LaunchDateTo != nil && launchDate.After(time.Time(*promo.LaunchDateTo)),
+		promo.DestinationId != nil && *promo.DestinationId != request.DestinationId,
+		promo.LaunchpadId != nil && *promo.LaunchpadId != request.LaunchpadId:
+		return newError(CodePromoCodeNotApplicable, "promo code %s can't be applied to this launch", promo.Code)
+	case promo.MaxUses != nil && promo.Uses >= *promo.MaxUses:
+		return newError(CodePromoCodeIsExhausted, "promo code %s is used up", promo.Code)
+	}
+	return nil
+}
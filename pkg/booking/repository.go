@@ -2,7 +2,11 @@ package booking
 
 import (
 	"database/sql"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+	"github.com/yosadchyi/space-booking/pkg/db"
 )
 
 type Repositories struct {
@@ -14,33 +18,200 @@ type Repositories struct {
 // MainRepository booking repository
 type MainRepository interface {
 	AddTx(tx *sql.Tx, booking *Booking) error
-	GetDestinationIdForBookingId(tx *sql.Tx, id string) (string, error)
+	Get(id string) (*Booking, error)
+	GetTx(tx *sql.Tx, id string) (*Booking, error)
+	GetForUpdateTx(tx *sql.Tx, id string) (*Booking, error)
 	GetAll() ([]Booking, error)
-	DeleteTx(tx *sql.Tx, id string) error
+	GetAllForPassenger(profileId string) ([]Booking, error)
+	UpdateStatusTx(tx *sql.Tx, id string, status BookingStatus) error
+	GetExpiredHoldIdsTx(tx *sql.Tx) ([]string, error)
+	GetExpiredHoldIdsInWeekTx(tx *sql.Tx, launchpadId string, date Date) ([]string, error)
+	GetPastConfirmedIdsTx(tx *sql.Tx, before time.Time) ([]string, error)
 }
 
 // DestinationRepository repository to access all launchpads
 type DestinationRepository interface {
 	Exists(id string) (bool, error)
+	Get(id string) (*Destination, error)
 	GetAll() ([]Destination, error)
+	GetAllByIds(ids []string) ([]Destination, error)
+}
+
+// GenderRepository repository to access allowed genders
+type GenderRepository interface {
+	GetAll() ([]Gender, error)
 }
 
 // LaunchpadRepository repository to access all launchpads
 type LaunchpadRepository interface {
 	ExistsAndIsActive(id string) (bool, error)
+	Get(id string) (*Launchpad, error)
+	GetCapacity(id string) (int, error)
 	AddOrUpdate(launchpad *Launchpad) error
 	GetAllActive() ([]Launchpad, error)
+	GetAllByIds(ids []string) ([]Launchpad, error)
 }
 
 // LaunchRepository repository to access all launches
 type LaunchRepository interface {
 	Add(launch *Launch) error
 	AddTx(tx *sql.Tx, launch *Launch) error
+	GetAllUpcoming() ([]Launch, error)
+	GetAllUpcomingImported() ([]Launch, error)
 	GetAllFromLaunchpadAtDate(launchpadId string, date Date) ([]Launch, error)
+	GetAllFromLaunchpadAtDateForUpdate(tx *sql.Tx, launchpadId string, date Date) ([]Launch, error)
 	GetWeekLaunches(tx *sql.Tx, launchpadId string, date Date) ([]Launch, error)
-	Delete(tx *sql.Tx, id string) error
+	GetTx(tx *sql.Tx, id string) (*Launch, error)
+	GetAllByIds(ids []string) ([]Launch, error)
+	Delete(id string) error
+	DeleteIfNotBooked(tx *sql.Tx, id string) (bool, error)
+}
+
+// WaitlistRepository repository to access waitlist entries
+type WaitlistRepository interface {
+	AddTx(tx *sql.Tx, entry *WaitlistEntry) error
+	GetAll() ([]WaitlistEntry, error)
+	GetWaitingFor(launchpadId string, date Date) ([]WaitlistEntry, error)
+	MarkBookedTx(tx *sql.Tx, id string, bookingId string) (bool, error)
+	RequeueTx(tx *sql.Tx, bookingId string, code string) (bool, error)
+	RecordOutcome(id string, code string) error
+	AddFreedSeats(launchpadId string, date Date) error
+	AddFreedSeatsTx(tx *sql.Tx, launchpadId string, date Date) error
+	ClaimFreedSeats(limit int, lease time.Duration) ([]FreedSeats, error)
+	DeleteFreedSeats(ids []int64) error
+}
+
+// PassengerRepository repository to access passenger profiles
+type PassengerRepository interface {
+	Add(profile *PassengerProfile) error
+	Get(id string) (*PassengerProfile, error)
+	GetAll() ([]PassengerProfile, error)
+	Update(profile *PassengerProfile) (bool, error)
+	Delete(id string) (bool, error)
+	HasBookings(id string) (bool, error)
+	FindOrAddTx(tx *sql.Tx, profile *PassengerProfile) error
+	LockIdentitiesTx(tx *sql.Tx, profiles []PassengerProfile) error
+	LockTx(tx *sql.Tx, ids []string) error
+	GetTravellingTx(tx *sql.Tx, ids []string, from Date, to Date) ([]string, error)
+	GetDuplicates() ([][]PassengerProfile, error)
+	MergeTx(tx *sql.Tx, id string, duplicateIds []string) error
+}
+
+// LoyaltyRepository repository to access frequent-flyer points
+type LoyaltyRepository interface {
+	AddTx(tx *sql.Tx, points *LoyaltyPoints) error
+	GetBalances(profileIds []string) (map[string]int64, error)
+	GetFlights(profileId string) ([]FlightHistoryItem, error)
+}
+
+// PaymentRepository repository to access payment transactions
+type PaymentRepository interface {
+	Add(transaction *PaymentTransaction) error
+	GetAllForBooking(bookingId string) ([]PaymentTransaction, error)
+	AddRefundTx(tx *sql.Tx, bookingId string, percent int) error
+	GetRefundForUpdateTx(tx *sql.Tx, bookingId string) (*PendingRefund, error)
+	ClaimRefundsTx(tx *sql.Tx, limit int) ([]PendingRefund, error)
+	MarkRefundedTx(tx *sql.Tx, bookingId string, amount int64) error
+	MarkRefundFailedTx(tx *sql.Tx, bookingId string, reason string, retryIn time.Duration) error
+}
+
+// PromoRepository repository to access promo codes and their redemptions
+type PromoRepository interface {
+	Add(promo *PromoCode) (bool, error)
+	Get(code string) (*PromoCode, error)
+	GetAll() ([]PromoCodeReport, error)
+	GetReport(code string) (*PromoCodeReport, error)
+	GetRedemptions(code string) ([]PromoRedemption, error)
+	Deactivate(code string) (bool, error)
+	RedeemTx(tx *sql.Tx, redemption *PromoRedemption) (bool, error)
+	ReleaseTx(tx *sql.Tx, bookingId string) error
+}
+
+// AuditRepository repository to access append-only audit log
+type AuditRepository interface {
+	AddTx(tx *sql.Tx, entry *AuditEntry) error
+	GetAllForBooking(bookingId string) ([]AuditEntry, error)
+}
+
+// EventRepository repository to access append-only event log
+type EventRepository interface {
+	AddTx(tx *sql.Tx, event *Event) error
+	GetAllAfter(id int64, limit int) ([]Event, error)
+	GetLastId() (int64, error)
+}
+
+// OutboxRepository repository to access events waiting to be published
+type OutboxRepository interface {
+	AddTx(tx *sql.Tx, aggregateId string, event *Event) error
+	Claim(limit int, lease time.Duration) ([]OutboxEntry, error)
+	MarkPublished(id int64) error
+	MarkFailed(id int64, publishedSinks []string, reason string, retryIn time.Duration) error
+	MarkDead(id int64, publishedSinks []string, reason string) error
+}
+
+// WebhookRepository repository to access webhook subscriptions and their deliveries
+type WebhookRepository interface {
+	Add(subscription *WebhookSubscription) error
+	Get(id string) (*WebhookSubscription, error)
+	GetAll() ([]WebhookSubscription, error)
+	Deactivate(id string) (bool, error)
+	AddDeliveries(event *Event) error
+	ClaimDue(limit int, lease time.Duration) ([]DueDelivery, error)
+	RecordAttempt(attempt *WebhookAttempt, status DeliveryStatus, retryIn time.Duration) error
+	GetDelivery(id string) (*WebhookDelivery, error)
+	GetAllDeliveries(subscriptionId string) ([]WebhookDelivery, error)
+	RetryDead(id string) (bool, error)
 }
 
+// activeBooking is a condition for bookings occupying seats, cancelled bookings and expired holds are not counted
+// even before holds are released
+const activeBooking = `(b.status IN ('confirmed', 'flown', 'no_show') OR (b.status = 'held' AND b.hold_expires_at > now()))`
+
+// bookedSeats counts seats occupied on launch l
+const bookedSeats = `(SELECT COUNT(*) FROM booking_passenger p JOIN booking b ON b.id = p.booking_id
+		WHERE b.launch_id = l.id AND ` + activeBooking + `)`
+
+// launchSelect selects launches along with number of seats which are still available,
+// launches without destination (imported from SpaceX) have no seats available
+const launchSelect = `SELECT
+		l.id, l.external_id, l.launchpad_id, l.destination_id, l.date, l.capacity,
+		CASE WHEN l.destination_id IS NULL THEN 0 ELSE l.capacity - ` + bookedSeats + ` END
+	FROM launch l`
+
+// launchInUse is a condition for launches which occupy launchpad, booked launches having only expired holds
+// are not counted
+const launchInUse = `(l.destination_id IS NULL OR EXISTS (
+		SELECT 1 FROM booking b WHERE b.launch_id = l.id AND ` + activeBooking + `))`
+
+// bookingSelect selects bookings along with number of seats which are still available on their launches
+const bookingSelect = `SELECT
+		o.id, o.status, o.hold_expires_at, o.launch_date, o.launchpad_id, o.destination_id, o.launch_id,
+		o.price, o.currency, o.contact_email, o.locale, COALESCE(l.capacity - ` + bookedSeats + `, 0)
+	FROM booking o LEFT JOIN launch l ON l.id = o.launch_id`
+
+const passengerSelect = `SELECT id, booking_id, profile_id, first_name, last_name, gender, birthday
+	FROM booking_passenger`
+
+const profileSelect = `SELECT id, first_name, last_name, gender, birthday, created_at FROM passenger_profile`
+
+// sameIdentity is a condition for passenger profiles p and d having the same identity
+const sameIdentity = `(lower(d.last_name) = lower(p.last_name) AND lower(d.first_name) = lower(p.first_name)
+		AND d.birthday = p.birthday)`
+
+// identityLockKey is a key of advisory lock of passenger identity, the same for profiles having the same identity
+const identityLockKey = `hashtext(lower(last_name) || '|' || lower(first_name) || '|' || birthday::TEXT)`
+
+const waitlistSelect = `SELECT
+		id, launchpad_id, destination_id, date_from, date_to, status, booking_id, outcome_code,
+		processed_at, created_at, payment_token, contact_email, locale
+	FROM waitlist_entry`
+
+const promoSelect = `SELECT
+		p.code, p.description, p.percent_off, p.amount_off, p.valid_from, p.valid_to, p.launch_date_from,
+		p.launch_date_to, p.destination_id, p.launchpad_id, p.max_uses, p.uses, p.stackable, p.active, p.created_at,
+		COALESCE((SELECT SUM(r.amount) FROM promo_redemption r WHERE r.code = p.code AND NOT r.released), 0)
+	FROM promo_code p`
+
 type mainRepository struct {
 	db *sql.DB
 }
@@ -49,6 +220,10 @@ type destinationRepository struct {
 	db *sql.DB
 }
 
+type genderRepository struct {
+	db *sql.DB
+}
+
 type launchpadRepository struct {
 	db *sql.DB
 }
@@ -57,58 +232,246 @@ type launchRepository struct {
 	db *sql.DB
 }
 
+type waitlistRepository struct {
+	db *sql.DB
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+type eventRepository struct {
+	db *sql.DB
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+type paymentRepository struct {
+	db *sql.DB
+}
+
+type passengerRepository struct {
+	db *sql.DB
+}
+
+type loyaltyRepository struct {
+	db *sql.DB
+}
+
+type promoRepository struct {
+	db *sql.DB
+}
+
 // NewMainRepository creates new bookings repository
 func NewMainRepository(db *sql.DB) MainRepository {
 	return &mainRepository{db: db}
 }
 
-// GetDestinationIdForBookingId returns destination id for given booking id
-func (m *mainRepository) GetDestinationIdForBookingId(tx *sql.Tx, id string) (string, error) {
-	row := tx.QueryRow("SELECT destination_id FROM booking WHERE id = $1", id)
-	destinationId := ""
-	err := row.Scan(&destinationId)
+// Get returns booking with its passengers
+func (m *mainRepository) Get(id string) (*Booking, error) {
+	return m.get(m.db, id)
+}
+
+// GetTx returns booking with its passengers in context of the given transaction
+func (m *mainRepository) GetTx(tx *sql.Tx, id string) (*Booking, error) {
+	return m.get(tx, id)
+}
+
+// GetForUpdateTx returns booking with its passengers and locks it until the end of the given transaction,
+// so concurrent changes of the booking are made one after another. Rows referencing the booking, e.g. payment
+// transactions, can still be added by other transactions meanwhile
+func (m *mainRepository) GetForUpdateTx(tx *sql.Tx, id string) (*Booking, error) {
+	row := tx.QueryRow("SELECT id FROM booking WHERE id = $1 FOR NO KEY UPDATE", id)
+	if err := row.Scan(&id); err != nil {
+		return nil, err
+	}
+	return m.get(tx, id)
+}
+
+func (m *mainRepository) get(q db.Querier, id string) (*Booking, error) {
+	booking, err := scanBooking(q.QueryRow(bookingSelect+" WHERE o.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	bookings := []Booking{*booking}
+	rows, err := q.Query(passengerSelect+" WHERE booking_id = $1 ORDER BY last_name, first_name, id", id)
+	if err != nil {
+		return nil, err
+	}
+	err = addPassengers(rows, bookings)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return destinationId, nil
+	return &bookings[0], nil
 }
 
-// AddTx adds new booking in context of the given transaction
+// AddTx adds new booking with its passengers in context of the given transaction
 func (m *mainRepository) AddTx(tx *sql.Tx, booking *Booking) error {
 	query := `INSERT INTO booking 
-    	(id, first_name, last_name, gender, birthday, launch_date, launchpad_id, destination_id, launch_id)
+    	(id, status, hold_expires_at, launch_date, launchpad_id, destination_id, launch_id, price, currency,
+    	 contact_email, locale)
 		VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	_, err := tx.Exec(query, booking.Id, booking.FirstName, booking.LastName, booking.Gender, time.Time(booking.Birthday),
-		time.Time(booking.LaunchDate), booking.LaunchpadId, booking.DestinationId, booking.LaunchId)
-	return err
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := tx.Exec(query, booking.Id, booking.Status, booking.HoldExpiresAt, time.Time(booking.LaunchDate),
+		booking.LaunchpadId, booking.DestinationId, booking.LaunchId, booking.Price, booking.Currency,
+		booking.ContactEmail, booking.Locale)
+	if err != nil {
+		return err
+	}
+	for _, passenger := range booking.Passengers {
+		_, err := tx.Exec(`INSERT INTO booking_passenger
+			(id, booking_id, profile_id, first_name, last_name, gender, birthday)
+			VALUES
+			($1, $2, $3, $4, $5, $6, $7)`,
+			passenger.Id, booking.Id, passenger.ProfileId, passenger.FirstName, passenger.LastName, passenger.Gender,
+			time.Time(passenger.Birthday))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// DeleteTx deletes booking in context of the given transaction
-func (m *mainRepository) DeleteTx(tx *sql.Tx, id string) error {
-	_, err := tx.Exec("DELETE FROM booking WHERE id = $1", id)
+// UpdateStatusTx changes status of the booking in context of the given transaction, hold expiration is cleared
+// for all statuses except held
+func (m *mainRepository) UpdateStatusTx(tx *sql.Tx, id string, status BookingStatus) error {
+	_, err := tx.Exec(`UPDATE booking SET status = $2::VARCHAR,
+		hold_expires_at = CASE WHEN $2::VARCHAR = 'held' THEN hold_expires_at END
+		WHERE id = $1`, id, status)
 	return err
 }
 
-// GetAll returns all bookings
+// GetExpiredHoldIdsTx returns ids of expired holds and locks them until the end of the given transaction
+func (m *mainRepository) GetExpiredHoldIdsTx(tx *sql.Tx) ([]string, error) {
+	return getIds(tx, `SELECT id FROM booking WHERE status = 'held' AND hold_expires_at <= now() ORDER BY id FOR UPDATE`)
+}
+
+// GetExpiredHoldIdsInWeekTx returns ids of expired holds from the launchpad in the week of given date and locks them
+// until the end of the given transaction
+func (m *mainRepository) GetExpiredHoldIdsInWeekTx(tx *sql.Tx, launchpadId string, date Date) ([]string, error) {
+	return getIds(tx, `SELECT id FROM booking WHERE status = 'held' AND hold_expires_at <= now()
+		AND launchpad_id = $1 AND date_trunc('week', launch_date) = date_trunc('week', $2::DATE) ORDER BY id FOR UPDATE`,
+		launchpadId, time.Time(date))
+}
+
+// GetPastConfirmedIdsTx returns ids of confirmed bookings with launch date before given time and locks them
+// until the end of the given transaction
+func (m *mainRepository) GetPastConfirmedIdsTx(tx *sql.Tx, before time.Time) ([]string, error) {
+	return getIds(tx, `SELECT id FROM booking WHERE status = 'confirmed' AND launch_date < $1::DATE
+		ORDER BY id FOR UPDATE`, before)
+}
+
+// GetAll returns all bookings with their passengers
 func (m *mainRepository) GetAll() ([]Booking, error) {
-	rows, err := m.db.Query(`SELECT 
-			id, first_name, last_name, gender, birthday, launch_date, launchpad_id, destination_id, launch_id
-		FROM booking ORDER BY id`)
+	rows, err := m.db.Query(bookingSelect + " ORDER BY o.id")
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := make([]Booking, 0, 1)
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return bookings, err
+		}
+		bookings = append(bookings, *booking)
+	}
+
+	rows, err = m.db.Query(passengerSelect + " ORDER BY booking_id, last_name, first_name, id")
+	if err != nil {
+		return nil, err
+	}
+	err = addPassengers(rows, bookings)
+	return bookings, err
+}
+
+// GetAllForPassenger returns all bookings of the passenger with their passengers, ordered by launch date
+func (m *mainRepository) GetAllForPassenger(profileId string) ([]Booking, error) {
+	rows, err := m.db.Query(bookingSelect+` WHERE o.id IN (SELECT booking_id FROM booking_passenger WHERE profile_id = $1)
+		ORDER BY o.launch_date, o.id`, profileId)
 	if err != nil {
 		return nil, err
 	}
 
 	bookings := make([]Booking, 0, 1)
+	ids := make([]string, 0, 1)
 	for rows.Next() {
-		booking := Booking{}
-		if err := rows.Scan(&booking.Id, &booking.FirstName, &booking.LastName, &booking.Gender,
-			&booking.Birthday, &booking.LaunchDate, &booking.LaunchpadId, &booking.DestinationId, &booking.LaunchId); err != nil {
+		booking, err := scanBooking(rows)
+		if err != nil {
 			return bookings, err
 		}
-		bookings = append(bookings, booking)
+		bookings = append(bookings, *booking)
+		ids = append(ids, booking.Id)
+	}
+
+	rows, err = m.db.Query(passengerSelect+" WHERE booking_id = ANY($1) ORDER BY booking_id, last_name, first_name, id",
+		pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	err = addPassengers(rows, bookings)
+	return bookings, err
+}
+
+// getIds returns ids selected by the given query
+func getIds(q db.Querier, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	for rows.Next() {
+		id := ""
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// scanBooking scans booking selected with bookingSelect
+func scanBooking(row interface{ Scan(...interface{}) error }) (*Booking, error) {
+	booking := Booking{Passengers: make([]Passenger, 0, 1)}
+	holdExpiresAt := sql.NullTime{}
+	launchId := sql.NullString{}
+	err := row.Scan(&booking.Id, &booking.Status, &holdExpiresAt, &booking.LaunchDate, &booking.LaunchpadId,
+		&booking.DestinationId, &launchId, &booking.Price, &booking.Currency, &booking.ContactEmail, &booking.Locale,
+		&booking.RemainingSeats)
+	if err != nil {
+		return nil, err
+	}
+	booking.LaunchId = launchId.String
+	if holdExpiresAt.Valid {
+		booking.HoldExpiresAt = &holdExpiresAt.Time
+	}
+	return &booking, nil
+}
+
+// addPassengers adds passengers selected with passengerSelect to their bookings
+func addPassengers(rows *sql.Rows, bookings []Booking) error {
+	index := make(map[string]int, len(bookings))
+	for i, booking := range bookings {
+		index[booking.Id] = i
+	}
+	for rows.Next() {
+		passenger := Passenger{}
+		bookingId := ""
+		if err := rows.Scan(&passenger.Id, &bookingId, &passenger.ProfileId, &passenger.FirstName,
+			&passenger.LastName, &passenger.Gender, &passenger.Birthday); err != nil {
+			return err
+		}
+		if i, ok := index[bookingId]; ok {
+			bookings[i].Passengers = append(bookings[i].Passengers, passenger)
+		}
 	}
-	return bookings, nil
+	return nil
 }
 
 // NewDestinationRepository creates new destination repository
@@ -122,17 +485,43 @@ func (d *destinationRepository) Exists(id string) (bool, error) {
 	return exists(row)
 }
 
+// Get returns destination by id
+func (d *destinationRepository) Get(id string) (*Destination, error) {
+	row := d.db.QueryRow("SELECT id, name, base_fare, distance_km, mission_days FROM destination WHERE id = $1", id)
+	destination := Destination{}
+	err := row.Scan(&destination.Id, &destination.Name, &destination.BaseFare, &destination.DistanceKm,
+		&destination.MissionDays)
+	if err != nil {
+		return nil, err
+	}
+	return &destination, nil
+}
+
 // GetAll returns all destinations
 func (d *destinationRepository) GetAll() ([]Destination, error) {
-	rows, err := d.db.Query("SELECT id, name FROM destination ORDER BY name")
+	rows, err := d.db.Query("SELECT id, name, base_fare, distance_km, mission_days FROM destination ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	return d.getDestinations(rows)
+}
+
+// GetAllByIds returns destinations with given ids, unknown ids are skipped
+func (d *destinationRepository) GetAllByIds(ids []string) ([]Destination, error) {
+	rows, err := d.db.Query(`SELECT id, name, base_fare, distance_km, mission_days FROM destination
+		WHERE id = ANY($1) ORDER BY name`, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
+	return d.getDestinations(rows)
+}
 
+func (d *destinationRepository) getDestinations(rows *sql.Rows) ([]Destination, error) {
 	destinations := make([]Destination, 0, 1)
 	for rows.Next() {
 		destination := Destination{}
-		if err := rows.Scan(&destination.Id, &destination.Name); err != nil {
+		if err := rows.Scan(&destination.Id, &destination.Name, &destination.BaseFare, &destination.DistanceKm,
+			&destination.MissionDays); err != nil {
 			return destinations, err
 		}
 		destinations = append(destinations, destination)
@@ -140,6 +529,28 @@ func (d *destinationRepository) GetAll() ([]Destination, error) {
 	return destinations, nil
 }
 
+// NewGenderRepository creates new gender repository
+func NewGenderRepository(db *sql.DB) GenderRepository {
+	return &genderRepository{db: db}
+}
+
+// GetAll returns all allowed genders
+func (g *genderRepository) GetAll() ([]Gender, error) {
+	rows, err := g.db.Query("SELECT value FROM gender_option ORDER BY position, value")
+	if err != nil {
+		return nil, err
+	}
+	genders := make([]Gender, 0, 4)
+	for rows.Next() {
+		gender := Gender("")
+		if err := rows.Scan(&gender); err != nil {
+			return genders, err
+		}
+		genders = append(genders, gender)
+	}
+	return genders, nil
+}
+
 // NewLaunchpadRepository create new launchpad repository
 func NewLaunchpadRepository(db *sql.DB) LaunchpadRepository {
 	return &launchpadRepository{db: db}
@@ -151,7 +562,31 @@ func (l *launchpadRepository) ExistsAndIsActive(id string) (bool, error) {
 	return exists(row)
 }
 
-// AddOrUpdate adds new or updates existing launchpad
+// Get returns launchpad by id
+func (l *launchpadRepository) Get(id string) (*Launchpad, error) {
+	row := l.db.QueryRow("SELECT id, name, status, capacity, fare_modifier_percent FROM launchpad WHERE id = $1", id)
+	launchpad := Launchpad{}
+	err := row.Scan(&launchpad.Id, &launchpad.Name, &launchpad.Status, &launchpad.Capacity,
+		&launchpad.FareModifierPercent)
+	if err != nil {
+		return nil, err
+	}
+	return &launchpad, nil
+}
+
+// GetCapacity returns number of passenger seats on launches from given launchpad
+func (l *launchpadRepository) GetCapacity(id string) (int, error) {
+	row := l.db.QueryRow("SELECT capacity FROM launchpad WHERE id = $1", id)
+	capacity := 0
+	err := row.Scan(&capacity)
+	if err != nil {
+		return 0, err
+	}
+	return capacity, nil
+}
+
+// AddOrUpdate adds new or updates existing launchpad, capacity and fare modifier are configured locally
+// and are never overwritten
 func (l *launchpadRepository) AddOrUpdate(launchpad *Launchpad) error {
 	query := `
 		INSERT INTO launchpad (id, name, status) VALUES ($1, $2, $3)
@@ -163,15 +598,30 @@ func (l *launchpadRepository) AddOrUpdate(launchpad *Launchpad) error {
 
 // GetAllActive returns all active launchpads
 func (l *launchpadRepository) GetAllActive() ([]Launchpad, error) {
-	rows, err := l.db.Query("SELECT id, name, status FROM launchpad WHERE status = 'active' ORDER BY id")
+	rows, err := l.db.Query(`SELECT id, name, status, capacity, fare_modifier_percent
+		FROM launchpad WHERE status = 'active' ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return l.getLaunchpads(rows)
+}
+
+// GetAllByIds returns launchpads with given ids regardless of their status, unknown ids are skipped
+func (l *launchpadRepository) GetAllByIds(ids []string) ([]Launchpad, error) {
+	rows, err := l.db.Query(`SELECT id, name, status, capacity, fare_modifier_percent
+		FROM launchpad WHERE id = ANY($1) ORDER BY id`, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
+	return l.getLaunchpads(rows)
+}
 
+func (l *launchpadRepository) getLaunchpads(rows *sql.Rows) ([]Launchpad, error) {
 	launchpads := make([]Launchpad, 0, 1)
 	for rows.Next() {
 		launchpad := Launchpad{}
-		if err := rows.Scan(&launchpad.Id, &launchpad.Name, &launchpad.Status); err != nil {
+		if err := rows.Scan(&launchpad.Id, &launchpad.Name, &launchpad.Status, &launchpad.Capacity,
+			&launchpad.FareModifierPercent); err != nil {
 			return launchpads, err
 		}
 		launchpads = append(launchpads, launchpad)
@@ -184,12 +634,13 @@ func NewLaunchRepository(db *sql.DB) LaunchRepository {
 	return &launchRepository{db: db}
 }
 
-// Add adds new launch, ignore duplicates
+// Add adds new imported launch, ignore duplicates
 func (l *launchRepository) Add(launch *Launch) error {
 	t := time.Time(launch.Date)
 	year, week := t.ISOWeek()
-	_, err := l.db.Exec("INSERT INTO launch (id, launchpad_id, date, year, week) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING",
-		launch.Id, launch.LaunchpadId, t, year, week)
+	_, err := l.db.Exec(`INSERT INTO launch (id, external_id, launchpad_id, date, year, week)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING`,
+		launch.Id, launch.ExternalId, launch.LaunchpadId, t, year, week)
 	return err
 }
 
@@ -197,14 +648,49 @@ func (l *launchRepository) Add(launch *Launch) error {
 func (l *launchRepository) AddTx(tx *sql.Tx, launch *Launch) error {
 	time := time.Time(launch.Date)
 	year, week := time.ISOWeek()
-	_, err := tx.Exec("INSERT INTO launch (id, launchpad_id, date, year, week) VALUES ($1, $2, $3, $4, $5)",
-		launch.Id, launch.LaunchpadId, time, year, week)
+	_, err := tx.Exec(`INSERT INTO launch (id, launchpad_id, destination_id, date, year, week, capacity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		launch.Id, launch.LaunchpadId, launch.DestinationId, time, year, week, launch.Capacity)
 	return err
 }
 
+// GetAllUpcoming returns all launches starting from today
+func (l *launchRepository) GetAllUpcoming() ([]Launch, error) {
+	rows, err := l.db.Query(launchSelect + " WHERE l.date >= CURRENT_DATE ORDER BY l.date, l.launchpad_id")
+	if err != nil {
+		return nil, err
+	}
+	return l.getLaunches(rows)
+}
+
+// GetAllUpcomingImported returns all upcoming launches imported from SpaceX
+func (l *launchRepository) GetAllUpcomingImported() ([]Launch, error) {
+	rows, err := l.db.Query(launchSelect + " WHERE l.external_id IS NOT NULL AND l.date >= CURRENT_DATE ORDER BY l.id")
+	if err != nil {
+		return nil, err
+	}
+	return l.getLaunches(rows)
+}
+
 // GetAllFromLaunchpadAtDate returns all launches from given launchpad at given date
 func (l *launchRepository) GetAllFromLaunchpadAtDate(launchpadId string, date Date) ([]Launch, error) {
-	rows, err := l.db.Query("SELECT id, launchpad_id, date FROM launch WHERE launchpad_id = $1 AND date = $2 ORDER BY id",
+	rows, err := l.db.Query(launchSelect+" WHERE l.launchpad_id = $1 AND l.date = $2 AND "+launchInUse+" ORDER BY l.id",
+		launchpadId, time.Time(date))
+	if err != nil {
+		return nil, err
+	}
+	return l.getLaunches(rows)
+}
+
+// GetAllFromLaunchpadAtDateForUpdate returns all launches from given launchpad at given date and locks them
+// until the end of the given transaction, so seats can't be taken concurrently
+func (l *launchRepository) GetAllFromLaunchpadAtDateForUpdate(tx *sql.Tx, launchpadId string, date Date) ([]Launch, error) {
+	_, err := tx.Exec("SELECT id FROM launch WHERE launchpad_id = $1 AND date = $2 FOR UPDATE",
+		launchpadId, time.Time(date))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(launchSelect+" WHERE l.launchpad_id = $1 AND l.date = $2 ORDER BY l.id",
 		launchpadId, time.Time(date))
 	if err != nil {
 		return nil, err
@@ -215,9 +701,8 @@ func (l *launchRepository) GetAllFromLaunchpadAtDate(launchpadId string, date Da
 // GetWeekLaunches returns launches at week corresponding to given date
 func (l *launchRepository) GetWeekLaunches(tx *sql.Tx, launchpadId string, date Date) ([]Launch, error) {
 	year, week := time.Time(date).ISOWeek()
-	rows, err := l.db.Query(`SELECT id, launchpad_id, date FROM launch 
-		WHERE launchpad_id = $1 AND year = $2 AND week = $3 ORDER BY id`,
-		launchpadId, year, week)
+	rows, err := tx.Query(launchSelect+" WHERE l.launchpad_id = $1 AND l.year = $2 AND l.week = $3 AND "+launchInUse+
+		" ORDER BY l.id", launchpadId, year, week)
 	if err != nil {
 		return nil, err
 	}
@@ -228,19 +713,1155 @@ func (l *launchRepository) getLaunches(rows *sql.Rows) ([]Launch, error) {
 	launches := make([]Launch, 0, 1)
 	for rows.Next() {
 		launch := Launch{}
-		if err := rows.Scan(&launch.Id, &launch.LaunchpadId, &launch.Date); err != nil {
+		externalId := sql.NullString{}
+		destinationId := sql.NullString{}
+		if err := rows.Scan(&launch.Id, &externalId, &launch.LaunchpadId, &destinationId, &launch.Date,
+			&launch.Capacity, &launch.RemainingSeats); err != nil {
 			return launches, err
 		}
+		launch.ExternalId = externalId.String
+		launch.DestinationId = destinationId.String
 		launches = append(launches, launch)
 	}
 	return launches, nil
 }
 
-func (l *launchRepository) Delete(tx *sql.Tx, id string) error {
-	_, err := tx.Exec("DELETE FROM launch WHERE id = $1", id)
+// GetTx returns launch in context of the given transaction
+func (l *launchRepository) GetTx(tx *sql.Tx, id string) (*Launch, error) {
+	rows, err := tx.Query(launchSelect+" WHERE l.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	launches, err := l.getLaunches(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(launches) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &launches[0], nil
+}
+
+// GetAllByIds returns launches with given ids, unknown ids are skipped
+func (l *launchRepository) GetAllByIds(ids []string) ([]Launch, error) {
+	rows, err := l.db.Query(launchSelect+" WHERE l.id = ANY($1) ORDER BY l.id", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return l.getLaunches(rows)
+}
+
+// Delete deletes launch
+func (l *launchRepository) Delete(id string) error {
+	_, err := l.db.Exec("DELETE FROM launch WHERE id = $1", id)
+	return err
+}
+
+// DeleteIfNotBooked deletes booked launch when there are no more active bookings for it, SpaceX launches are kept.
+// Returns true if launch was deleted
+func (l *launchRepository) DeleteIfNotBooked(tx *sql.Tx, id string) (bool, error) {
+	result, err := tx.Exec(`DELETE FROM launch
+		WHERE id = $1 AND destination_id IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM booking b WHERE b.launch_id = $1 AND `+activeBooking+`)`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// NewWaitlistRepository creates new waitlist repository
+func NewWaitlistRepository(db *sql.DB) WaitlistRepository {
+	return &waitlistRepository{db: db}
+}
+
+// AddTx adds new waitlist entry with its passengers in context of the given transaction
+func (w *waitlistRepository) AddTx(tx *sql.Tx, entry *WaitlistEntry) error {
+	_, err := tx.Exec(`INSERT INTO waitlist_entry
+		(id, launchpad_id, destination_id, date_from, date_to, status, payment_token, contact_email, locale)
+		VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		entry.Id, entry.LaunchpadId, entry.DestinationId, time.Time(entry.DateFrom), time.Time(entry.DateTo),
+		entry.Status, entry.PaymentToken, entry.ContactEmail, entry.Locale)
+	if err != nil {
+		return err
+	}
+	for i, passenger := range entry.Passengers {
+		_, err := tx.Exec(`INSERT INTO waitlist_passenger
+			(waitlist_entry_id, position, profile_id, first_name, last_name, gender, birthday)
+			VALUES
+			($1, $2, $3, $4, $5, $6, $7)`,
+			entry.Id, i, nullString(passenger.ProfileId), passenger.FirstName, passenger.LastName, passenger.Gender,
+			time.Time(passenger.Birthday))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAll returns all waitlist entries in order they were added
+func (w *waitlistRepository) GetAll() ([]WaitlistEntry, error) {
+	rows, err := w.db.Query(waitlistSelect + " ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	return w.getEntries(rows)
+}
+
+// GetWaitingFor returns entries still waiting for given launchpad at given date in order they were added
+func (w *waitlistRepository) GetWaitingFor(launchpadId string, date Date) ([]WaitlistEntry, error) {
+	rows, err := w.db.Query(waitlistSelect+` WHERE launchpad_id = $1 AND status = $2 AND date_from <= $3 AND date_to >= $3
+		ORDER BY created_at, id`, launchpadId, WAITING, time.Time(date))
+	if err != nil {
+		return nil, err
+	}
+	return w.getEntries(rows)
+}
+
+// MarkBookedTx marks waiting entry as converted into booking with given id in context of the transaction adding
+// the booking, false is returned if entry is not waiting anymore
+func (w *waitlistRepository) MarkBookedTx(tx *sql.Tx, id string, bookingId string) (bool, error) {
+	result, err := tx.Exec(`UPDATE waitlist_entry SET status = $2, booking_id = $3, outcome_code = NULL,
+		processed_at = now() WHERE id = $1 AND status = $4`, id, BOOKED, bookingId, WAITING)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RequeueTx puts entry converted into booking with given id back to the waitlist with outcome of the booking,
+// e.g. when booking can't be paid, returns false if booking is not made for a waitlist entry
+func (w *waitlistRepository) RequeueTx(tx *sql.Tx, bookingId string, code string) (bool, error) {
+	result, err := tx.Exec(`UPDATE waitlist_entry SET status = $2, booking_id = NULL, outcome_code = $3,
+		processed_at = now() WHERE booking_id = $1 AND status = $4`, bookingId, WAITING, code, BOOKED)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RecordOutcome records result of unsuccessful booking attempt, entry keeps waiting
+func (w *waitlistRepository) RecordOutcome(id string, code string) error {
+	_, err := w.db.Exec("UPDATE waitlist_entry SET outcome_code = $2, processed_at = now() WHERE id = $1", id, code)
+	return err
+}
+
+// AddFreedSeats records seats freed on the launchpad at the launch date to be offered to the waitlist
+func (w *waitlistRepository) AddFreedSeats(launchpadId string, date Date) error {
+	_, err := w.db.Exec("INSERT INTO freed_seats(launchpad_id, launch_date) VALUES ($1, $2)", launchpadId,
+		time.Time(date))
+	return err
+}
+
+// AddFreedSeatsTx records seats freed on the launchpad at the launch date in context of the transaction
+// freeing them, so seats are offered to the waitlist only if the transaction is committed
+func (w *waitlistRepository) AddFreedSeatsTx(tx *sql.Tx, launchpadId string, date Date) error {
+	_, err := tx.Exec("INSERT INTO freed_seats(launchpad_id, launch_date) VALUES ($1, $2)", launchpadId,
+		time.Time(date))
+	return err
+}
+
+// ClaimFreedSeats returns at most limit freed seats which are due. Claimed seats are postponed by lease, so they
+// are not claimed again by concurrent workers while the waitlist is processed and are offered again if worker stops
+func (w *waitlistRepository) ClaimFreedSeats(limit int, lease time.Duration) ([]FreedSeats, error) {
+	rows, err := w.db.Query(`WITH due AS (
+			SELECT id FROM freed_seats WHERE next_attempt_at <= now()
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		UPDATE freed_seats f SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM due WHERE f.id = due.id
+		RETURNING f.id, f.launchpad_id, f.launch_date`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	freed := make([]FreedSeats, 0, 1)
+	for rows.Next() {
+		seats := FreedSeats{}
+		if err := rows.Scan(&seats.Id, &seats.LaunchpadId, &seats.LaunchDate); err != nil {
+			return freed, err
+		}
+		freed = append(freed, seats)
+	}
+	return freed, nil
+}
+
+// DeleteFreedSeats deletes freed seats which are offered to the waitlist
+func (w *waitlistRepository) DeleteFreedSeats(ids []int64) error {
+	_, err := w.db.Exec("DELETE FROM freed_seats WHERE id = ANY($1)", pq.Array(ids))
 	return err
 }
 
+func (w *waitlistRepository) getEntries(rows *sql.Rows) ([]WaitlistEntry, error) {
+	entries := make([]WaitlistEntry, 0, 1)
+	index := make(map[string]int)
+	for rows.Next() {
+		entry := WaitlistEntry{Passengers: make([]PassengerRequest, 0, 1)}
+		bookingId := sql.NullString{}
+		outcomeCode := sql.NullString{}
+		processedAt := sql.NullTime{}
+		if err := rows.Scan(&entry.Id, &entry.LaunchpadId, &entry.DestinationId, &entry.DateFrom, &entry.DateTo,
+			&entry.Status, &bookingId, &outcomeCode, &processedAt, &entry.CreatedAt, &entry.PaymentToken,
+			&entry.ContactEmail, &entry.Locale); err != nil {
+			return entries, err
+		}
+		entry.BookingId = bookingId.String
+		entry.OutcomeCode = outcomeCode.String
+		if processedAt.Valid {
+			entry.ProcessedAt = &processedAt.Time
+		}
+		index[entry.Id] = len(entries)
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.Id)
+	}
+	rows, err := w.db.Query(`SELECT waitlist_entry_id, COALESCE(profile_id::VARCHAR, ''), first_name, last_name,
+		gender, birthday
+		FROM waitlist_passenger WHERE waitlist_entry_id = ANY($1) ORDER BY waitlist_entry_id, position`,
+		pq.Array(ids))
+	if err != nil {
+		return entries, err
+	}
+	for rows.Next() {
+		passenger := PassengerRequest{}
+		entryId := ""
+		if err := rows.Scan(&entryId, &passenger.ProfileId, &passenger.FirstName, &passenger.LastName,
+			&passenger.Gender, &passenger.Birthday); err != nil {
+			return entries, err
+		}
+		if i, ok := index[entryId]; ok {
+			entries[i].Passengers = append(entries[i].Passengers, passenger)
+		}
+	}
+	return entries, nil
+}
+
+// NewAuditRepository creates new audit repository
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// AddTx appends entry to the audit log in context of the given transaction
+func (a *auditRepository) AddTx(tx *sql.Tx, entry *AuditEntry) error {
+	row := tx.QueryRow(`INSERT INTO audit_log
+		(booking_id, entity, entity_id, action, actor, before, after)
+		VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		entry.BookingId, entry.Entity, entry.EntityId, entry.Action, entry.Actor, nullJson(entry.Before),
+		nullJson(entry.After))
+	return row.Scan(&entry.Id, &entry.CreatedAt)
+}
+
+// GetAllForBooking returns audit log of the booking in order changes were made
+func (a *auditRepository) GetAllForBooking(bookingId string) ([]AuditEntry, error) {
+	rows, err := a.db.Query(`SELECT id, booking_id, entity, entity_id, action, actor, created_at, before, after
+		FROM audit_log WHERE booking_id = $1 ORDER BY id`, bookingId)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, 1)
+	for rows.Next() {
+		entry := AuditEntry{}
+		before := make([]byte, 0)
+		after := make([]byte, 0)
+		if err := rows.Scan(&entry.Id, &entry.BookingId, &entry.Entity, &entry.EntityId, &entry.Action, &entry.Actor,
+			&entry.CreatedAt, &before, &after); err != nil {
+			return entries, err
+		}
+		if len(before) > 0 {
+			entry.Before = before
+		}
+		if len(after) > 0 {
+			entry.After = after
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// NewEventRepository creates new event repository
+func NewEventRepository(db *sql.DB) EventRepository {
+	return &eventRepository{db: db}
+}
+
+// AddTx appends event to the event log in context of the given transaction, id of the transaction is recorded
+// along with the event, so readers hold it back until the transaction is finished
+func (e *eventRepository) AddTx(tx *sql.Tx, event *Event) error {
+	row := tx.QueryRow(`INSERT INTO event_log (type, entity_id, payload) VALUES ($1, $2, $3)
+		RETURNING id, created_at`, event.Type, event.EntityId, []byte(event.Payload))
+	return row.Scan(&event.Id, &event.CreatedAt)
+}
+
+// finishedEvent is a condition for events recorded by transactions older than any transaction in progress,
+// no more events can be recorded before them in the stream order (tx_id, id)
+const finishedEvent = `tx_id < pg_snapshot_xmin(pg_current_snapshot())`
+
+// GetAllAfter returns at most limit events following the event with given id in order of transactions which
+// recorded them. Events of transactions which are in progress or may commit before those in progress are held back,
+// so events are never added to the stream before already returned ones
+func (e *eventRepository) GetAllAfter(id int64, limit int) ([]Event, error) {
+	rows, err := e.db.Query(`SELECT id, type, entity_id, created_at, payload FROM event_log
+		WHERE `+finishedEvent+` AND (tx_id, id) > (COALESCE((SELECT tx_id FROM event_log WHERE id = $1), '0'), $1)
+		ORDER BY tx_id, id LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, 1)
+	for rows.Next() {
+		event := Event{}
+		payload := make([]byte, 0)
+		if err := rows.Scan(&event.Id, &event.Type, &event.EntityId, &event.CreatedAt, &payload); err != nil {
+			return events, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// GetLastId returns id of the last event in stream order which is not held back or 0 if there is no such event
+func (e *eventRepository) GetLastId() (int64, error) {
+	row := e.db.QueryRow(`SELECT COALESCE((SELECT id FROM event_log WHERE ` + finishedEvent + `
+		ORDER BY tx_id DESC, id DESC LIMIT 1), 0)`)
+	id := int64(0)
+	err := row.Scan(&id)
+	return id, err
+}
+
+// NewWebhookRepository creates new webhook repository
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+const webhookSelect = `SELECT id, url, event_types, secret, active, created_at FROM webhook_subscription`
+
+const deliverySelect = `SELECT d.id, d.subscription_id, d.event_id, e.type, d.status, d.attempts, d.next_attempt_at,
+		d.created_at, d.delivered_at
+	FROM webhook_delivery d JOIN event_log e ON e.id = d.event_id`
+
+// Add adds new webhook subscription
+func (w *webhookRepository) Add(subscription *WebhookSubscription) error {
+	row := w.db.QueryRow(`INSERT INTO webhook_subscription (id, url, event_types, secret) VALUES ($1, $2, $3, $4)
+		RETURNING active, created_at`,
+		subscription.Id, subscription.Url, pq.Array(eventTypeNames(subscription.EventTypes)), subscription.Secret)
+	return row.Scan(&subscription.Active, &subscription.CreatedAt)
+}
+
+// Get returns webhook subscription by id
+func (w *webhookRepository) Get(id string) (*WebhookSubscription, error) {
+	return scanWebhook(w.db.QueryRow(webhookSelect+" WHERE id = $1", id))
+}
+
+// GetAll returns all webhook subscriptions
+func (w *webhookRepository) GetAll() ([]WebhookSubscription, error) {
+	rows, err := w.db.Query(webhookSelect + " ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make([]WebhookSubscription, 0, 1)
+	for rows.Next() {
+		subscription, err := scanWebhook(rows)
+		if err != nil {
+			return subscriptions, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions, nil
+}
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*WebhookSubscription, error) {
+	subscription := WebhookSubscription{}
+	types := make([]string, 0)
+	err := row.Scan(&subscription.Id, &subscription.Url, pq.Array(&types), &subscription.Secret,
+		&subscription.Active, &subscription.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, eventType := range types {
+		subscription.EventTypes = append(subscription.EventTypes, EventType(eventType))
+	}
+	return &subscription, nil
+}
+
+func eventTypeNames(types []EventType) []string {
+	names := make([]string, 0, len(types))
+	for _, eventType := range types {
+		names = append(names, string(eventType))
+	}
+	return names
+}
+
+// Deactivate deactivates webhook subscription, pending deliveries are still attempted
+func (w *webhookRepository) Deactivate(id string) (bool, error) {
+	result, err := w.db.Exec("UPDATE webhook_subscription SET active = FALSE WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// AddDeliveries adds pending deliveries of the event to all active subscriptions to its type,
+// deliveries which already exist are kept
+func (w *webhookRepository) AddDeliveries(event *Event) error {
+	_, err := w.db.Exec(`INSERT INTO webhook_delivery (id, subscription_id, event_id, aggregate_id, next_attempt_at)
+		SELECT gen_random_uuid(), s.id, $1, $3, now() FROM webhook_subscription s
+		WHERE s.active AND $2 = ANY(s.event_types)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`, event.Id, event.Type, event.AggregateId)
+	return err
+}
+
+// ClaimDue returns at most limit pending deliveries which are due, oldest events first. Delivery is not claimed
+// while earlier delivery of the same aggregate to the subscription is pending, so partners get events
+// of the aggregate in order. Claimed deliveries are postponed by lease, so they are not claimed again while being
+// delivered
+func (w *webhookRepository) ClaimDue(limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := w.db.Query(`WITH due AS (
+			SELECT id FROM webhook_delivery d
+			WHERE status = 'pending' AND next_attempt_at <= now() AND NOT EXISTS (
+				SELECT 1 FROM webhook_delivery p WHERE p.subscription_id = d.subscription_id
+					AND p.aggregate_id = d.aggregate_id AND p.status = 'pending' AND p.seq < d.seq)
+			ORDER BY event_id, id LIMIT $1 FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_delivery d SET next_attempt_at = now() + make_interval(secs => $2)
+			FROM due WHERE d.id = due.id
+			RETURNING d.id, d.subscription_id, d.event_id, d.attempts
+		)
+		SELECT c.id, c.subscription_id, c.event_id, c.attempts, s.url, s.secret, e.type, e.entity_id, e.created_at,
+			e.payload
+		FROM claimed c
+			JOIN webhook_subscription s ON s.id = c.subscription_id
+			JOIN event_log e ON e.id = c.event_id
+		ORDER BY c.event_id, c.id`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]DueDelivery, 0, 1)
+	for rows.Next() {
+		due := DueDelivery{}
+		payload := make([]byte, 0)
+		if err := rows.Scan(&due.Delivery.Id, &due.Delivery.SubscriptionId, &due.Delivery.EventId,
+			&due.Delivery.Attempts, &due.Subscription.Url, &due.Subscription.Secret, &due.Event.Type,
+			&due.Event.EntityId, &due.Event.CreatedAt, &payload); err != nil {
+			return deliveries, err
+		}
+		due.Delivery.Status = PENDING
+		due.Delivery.EventType = due.Event.Type
+		due.Subscription.Id = due.Delivery.SubscriptionId
+		due.Event.Id = due.Delivery.EventId
+		due.Event.Payload = payload
+		deliveries = append(deliveries, due)
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt appends attempt to the delivery log and moves delivery to given status,
+// pending delivery is attempted again after retryIn
+func (w *webhookRepository) RecordAttempt(attempt *WebhookAttempt, status DeliveryStatus,
+	retryIn time.Duration) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	row := tx.QueryRow(`INSERT INTO webhook_attempt (delivery_id, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, attempted_at`, attempt.DeliveryId, attempt.StatusCode, attempt.Error, attempt.DurationMs)
+	err = row.Scan(&attempt.Id, &attempt.AttemptedAt)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`UPDATE webhook_delivery SET status = $2::VARCHAR, attempts = attempts + 1,
+		next_attempt_at = CASE WHEN $2::VARCHAR = 'pending' THEN now() + make_interval(secs => $3) END,
+		delivered_at = CASE WHEN $2::VARCHAR = 'delivered' THEN now() END
+		WHERE id = $1`, attempt.DeliveryId, status, retryIn.Seconds())
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDelivery returns delivery by id along with its log
+func (w *webhookRepository) GetDelivery(id string) (*WebhookDelivery, error) {
+	rows, err := w.db.Query(deliverySelect+" WHERE d.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := w.getDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &deliveries[0], nil
+}
+
+// GetAllDeliveries returns deliveries to the subscription along with their logs, latest events first
+func (w *webhookRepository) GetAllDeliveries(subscriptionId string) ([]WebhookDelivery, error) {
+	rows, err := w.db.Query(deliverySelect+" WHERE d.subscription_id = $1 ORDER BY d.event_id DESC", subscriptionId)
+	if err != nil {
+		return nil, err
+	}
+	return w.getDeliveries(rows)
+}
+
+func (w *webhookRepository) getDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	deliveries := make([]WebhookDelivery, 0, 1)
+	ids := make([]string, 0, 1)
+	index := make(map[string]int)
+	for rows.Next() {
+		delivery := WebhookDelivery{Log: make([]WebhookAttempt, 0)}
+		if err := rows.Scan(&delivery.Id, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType,
+			&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt,
+			&delivery.DeliveredAt); err != nil {
+			return deliveries, err
+		}
+		index[delivery.Id] = len(deliveries)
+		ids = append(ids, delivery.Id)
+		deliveries = append(deliveries, delivery)
+	}
+
+	rows, err := w.db.Query(`SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+		FROM webhook_attempt WHERE delivery_id = ANY($1) ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		attempt := WebhookAttempt{}
+		if err := rows.Scan(&attempt.Id, &attempt.DeliveryId, &attempt.AttemptedAt, &attempt.StatusCode,
+			&attempt.Error, &attempt.DurationMs); err != nil {
+			return deliveries, err
+		}
+		delivery := &deliveries[index[attempt.DeliveryId]]
+		delivery.Log = append(delivery.Log, attempt)
+	}
+	return deliveries, nil
+}
+
+// RetryDead moves dead delivery back to pending, so it is attempted again with full number of attempts.
+// Returns false if delivery does not exist or is not dead
+func (w *webhookRepository) RetryDead(id string) (bool, error) {
+	result, err := w.db.Exec(`UPDATE webhook_delivery SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND status = 'dead'`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// NewOutboxRepository creates new outbox repository
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// AddTx adds recorded event to the outbox in context of the transaction which recorded it
+func (o *outboxRepository) AddTx(tx *sql.Tx, aggregateId string, event *Event) error {
+	_, err := tx.Exec("INSERT INTO outbox (aggregate_id, event_id) VALUES ($1, $2)", aggregateId, event.Id)
+	return err
+}
+
+// pendingOutbox is a condition for outbox entries which are neither published nor dead
+const pendingOutbox = `published_at IS NULL AND dead_at IS NULL`
+
+// Claim returns at most limit pending entries which are due, only the first pending entry of every aggregate
+// is returned, so entries of the aggregate are published in order. Claimed entries are postponed by lease,
+// so they are not claimed again by concurrent relays while being published
+func (o *outboxRepository) Claim(limit int, lease time.Duration) ([]OutboxEntry, error) {
+	rows, err := o.db.Query(`WITH due AS (
+			SELECT o.id FROM outbox o
+			WHERE o.`+pendingOutbox+` AND o.next_attempt_at <= now() AND NOT EXISTS (
+				SELECT 1 FROM outbox p WHERE p.aggregate_id = o.aggregate_id AND p.`+pendingOutbox+` AND p.id < o.id)
+			ORDER BY o.id LIMIT $1 FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE outbox o SET next_attempt_at = now() + make_interval(secs => $2)
+			FROM due WHERE o.id = due.id
+			RETURNING o.id, o.aggregate_id, o.attempts, o.published_sinks, o.event_id
+		)
+		SELECT c.id, c.aggregate_id, c.attempts, c.published_sinks, e.id, e.type, e.entity_id, e.created_at, e.payload
+		FROM claimed c JOIN event_log e ON e.id = c.event_id
+		ORDER BY c.id`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]OutboxEntry, 0, 1)
+	for rows.Next() {
+		entry := OutboxEntry{}
+		payload := make([]byte, 0)
+		if err := rows.Scan(&entry.Id, &entry.AggregateId, &entry.Attempts, pq.Array(&entry.PublishedSinks),
+			&entry.Event.Id, &entry.Event.Type, &entry.Event.EntityId, &entry.Event.CreatedAt, &payload); err != nil {
+			return entries, err
+		}
+		entry.Event.Payload = payload
+		entry.Event.AggregateId = entry.AggregateId
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// MarkPublished marks entry as published to all sinks
+func (o *outboxRepository) MarkPublished(id int64) error {
+	_, err := o.db.Exec("UPDATE outbox SET published_at = now(), last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+// MarkFailed records failure to publish the entry along with sinks which accepted it, it is retried after retryIn
+func (o *outboxRepository) MarkFailed(id int64, publishedSinks []string, reason string, retryIn time.Duration) error {
+	_, err := o.db.Exec(`UPDATE outbox SET attempts = attempts + 1, published_sinks = $2, last_error = $3,
+		next_attempt_at = now() + make_interval(secs => $4) WHERE id = $1`, id, pq.Array(publishedSinks), reason,
+		retryIn.Seconds())
+	return err
+}
+
+// MarkDead records the last failure to publish the entry, it is not retried anymore
+func (o *outboxRepository) MarkDead(id int64, publishedSinks []string, reason string) error {
+	_, err := o.db.Exec(`UPDATE outbox SET attempts = attempts + 1, published_sinks = $2, last_error = $3,
+		dead_at = now() WHERE id = $1`, id, pq.Array(publishedSinks), reason)
+	return err
+}
+
+// NewPassengerRepository creates new passenger profile repository
+func NewPassengerRepository(db *sql.DB) PassengerRepository {
+	return &passengerRepository{db: db}
+}
+
+// Add adds new passenger profile
+func (p *passengerRepository) Add(profile *PassengerProfile) error {
+	return addProfile(p.db, profile)
+}
+
+// Get returns passenger profile
+func (p *passengerRepository) Get(id string) (*PassengerProfile, error) {
+	return scanProfile(p.db.QueryRow(profileSelect+" WHERE id = $1", id))
+}
+
+// GetAll returns all passenger profiles ordered by name
+func (p *passengerRepository) GetAll() ([]PassengerProfile, error) {
+	return getProfiles(p.db, profileSelect+" ORDER BY last_name, first_name, birthday, id")
+}
+
+// Update updates passenger profile, returns false if profile does not exist
+func (p *passengerRepository) Update(profile *PassengerProfile) (bool, error) {
+	row := p.db.QueryRow(`UPDATE passenger_profile SET first_name = $2, last_name = $3, gender = $4, birthday = $5
+		WHERE id = $1 RETURNING created_at`,
+		profile.Id, profile.FirstName, profile.LastName, profile.Gender, time.Time(profile.Birthday))
+	err := row.Scan(&profile.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete deletes passenger profile, returns false if profile does not exist
+func (p *passengerRepository) Delete(id string) (bool, error) {
+	result, err := p.db.Exec("DELETE FROM passenger_profile WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// HasBookings checks if passenger has any bookings
+func (p *passengerRepository) HasBookings(id string) (bool, error) {
+	return exists(p.db.QueryRow("SELECT EXISTS(SELECT 1 FROM booking_passenger WHERE profile_id = $1)", id))
+}
+
+// FindOrAddTx looks up passenger profile with the same identity in context of the given transaction,
+// profile is added if it is not found. Identity is expected to be locked with LockIdentitiesTx, so the same
+// passenger is not added twice by concurrent transactions
+func (p *passengerRepository) FindOrAddTx(tx *sql.Tx, profile *PassengerProfile) error {
+	found, err := scanProfile(tx.QueryRow(profileSelect+` WHERE lower(first_name) = lower($1)
+		AND lower(last_name) = lower($2) AND birthday = $3 ORDER BY created_at, id LIMIT 1`,
+		profile.FirstName, profile.LastName, time.Time(profile.Birthday)))
+	if err == sql.ErrNoRows {
+		return addProfile(tx, profile)
+	}
+	if err != nil {
+		return err
+	}
+	*profile = *found
+	return nil
+}
+
+// LockIdentitiesTx locks identities given by name and birthday of the profiles until the end of the given
+// transaction, the same locks are taken by LockTx for existing profiles. Locks are taken in order to avoid deadlocks
+func (p *passengerRepository) LockIdentitiesTx(tx *sql.Tx, profiles []PassengerProfile) error {
+	lastNames := make([]string, 0, len(profiles))
+	firstNames := make([]string, 0, len(profiles))
+	birthdays := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		lastNames = append(lastNames, profile.LastName)
+		firstNames = append(firstNames, profile.FirstName)
+		birthdays = append(birthdays, profile.Birthday.Format("2006-01-02"))
+	}
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(l.key) FROM (
+			SELECT DISTINCT `+identityLockKey+` AS key
+			FROM unnest($1::TEXT[], $2::TEXT[], $3::DATE[]) AS i(last_name, first_name, birthday) ORDER BY key) l`,
+		pq.Array(lastNames), pq.Array(firstNames), pq.Array(birthdays))
+	return err
+}
+
+// LockTx locks identities of passenger profiles until the end of the given transaction, so profiles having
+// the same identity are locked together even if they are not merged. Locks are taken in order to avoid deadlocks
+func (p *passengerRepository) LockTx(tx *sql.Tx, ids []string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(l.key) FROM (
+			SELECT DISTINCT `+identityLockKey+` AS key
+			FROM passenger_profile WHERE id = ANY($1) ORDER BY key) l`, pq.Array(ids))
+	return err
+}
+
+// GetTravellingTx returns ids of given passengers which are on a mission at any day from the given date
+// and before the end date in context of the given transaction. Passengers having the same identity are
+// considered the same person even if their profiles are not merged
+func (p *passengerRepository) GetTravellingTx(tx *sql.Tx, ids []string, from Date, to Date) ([]string, error) {
+	return getIds(tx, `SELECT DISTINCT d.id FROM passenger_profile d
+			JOIN passenger_profile p ON p.id = d.id OR `+sameIdentity+`
+			JOIN booking_passenger bp ON bp.profile_id = p.id
+			JOIN booking b ON b.id = bp.booking_id
+			JOIN destination t ON t.id = b.destination_id
+		WHERE d.id = ANY($1) AND `+activeBooking+` AND b.launch_date < $3 AND b.launch_date + t.mission_days > $2
+		ORDER BY d.id`,
+		pq.Array(ids), time.Time(from), time.Time(to))
+}
+
+// GetDuplicates returns groups of passenger profiles having the same identity
+func (p *passengerRepository) GetDuplicates() ([][]PassengerProfile, error) {
+	profiles, err := getProfiles(p.db, profileSelect+` d WHERE EXISTS (
+			SELECT 1 FROM passenger_profile p WHERE p.id <> d.id AND `+sameIdentity+`)
+		ORDER BY lower(d.last_name), lower(d.first_name), d.birthday, d.created_at, d.id`)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([][]PassengerProfile, 0)
+	for i, profile := range profiles {
+		if i == 0 || !sameProfileIdentity(profiles[i-1], profile) {
+			groups = append(groups, make([]PassengerProfile, 0, 2))
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], profile)
+	}
+	return groups, nil
+}
+
+// MergeTx moves bookings and waitlist entries of duplicate profiles to the profile with given id
+// and deletes duplicates in context of the given transaction
+func (p *passengerRepository) MergeTx(tx *sql.Tx, id string, duplicateIds []string) error {
+	_, err := tx.Exec("UPDATE booking_passenger SET profile_id = $1 WHERE profile_id = ANY($2)", id,
+		pq.Array(duplicateIds))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE waitlist_passenger SET profile_id = $1 WHERE profile_id = ANY($2)", id,
+		pq.Array(duplicateIds))
+	if err != nil {
+		return err
+	}
+	// points of duplicates flying on the same booking are accrued only once, so one row per booking is moved
+	_, err = tx.Exec(`UPDATE loyalty_points SET profile_id = $1 WHERE id IN (
+			SELECT DISTINCT ON (booking_id) id FROM loyalty_points WHERE profile_id = ANY($2)
+				AND booking_id NOT IN (SELECT booking_id FROM loyalty_points WHERE profile_id = $1)
+			ORDER BY booking_id, created_at, id)`, id, pq.Array(duplicateIds))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM loyalty_points WHERE profile_id = ANY($1)", pq.Array(duplicateIds))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM passenger_profile WHERE id = ANY($1)", pq.Array(duplicateIds))
+	return err
+}
+
+func addProfile(q db.Querier, profile *PassengerProfile) error {
+	row := q.QueryRow(`INSERT INTO passenger_profile
+		(id, first_name, last_name, gender, birthday)
+		VALUES
+		($1, $2, $3, $4, $5)
+		RETURNING created_at`,
+		profile.Id, profile.FirstName, profile.LastName, profile.Gender, time.Time(profile.Birthday))
+	return row.Scan(&profile.CreatedAt)
+}
+
+func getProfiles(q db.Querier, query string, args ...interface{}) ([]PassengerProfile, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]PassengerProfile, 0, 1)
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return profiles, err
+		}
+		profiles = append(profiles, *profile)
+	}
+	return profiles, nil
+}
+
+func scanProfile(row interface{ Scan(...interface{}) error }) (*PassengerProfile, error) {
+	profile := PassengerProfile{}
+	err := row.Scan(&profile.Id, &profile.FirstName, &profile.LastName, &profile.Gender, &profile.Birthday,
+		&profile.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// sameProfileIdentity checks if passenger profiles have the same identity, matches sameIdentity condition
+func sameProfileIdentity(a PassengerProfile, b PassengerProfile) bool {
+	return strings.EqualFold(a.LastName, b.LastName) && strings.EqualFold(a.FirstName, b.FirstName) &&
+		time.Time(a.Birthday).Equal(time.Time(b.Birthday))
+}
+
+// nullString converts empty string to SQL NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// NewLoyaltyRepository creates new loyalty repository
+func NewLoyaltyRepository(db *sql.DB) LoyaltyRepository {
+	return &loyaltyRepository{db: db}
+}
+
+// AddTx accrues points in context of the given transaction, points are accrued only once per passenger and booking
+func (l *loyaltyRepository) AddTx(tx *sql.Tx, points *LoyaltyPoints) error {
+	_, err := tx.Exec(`INSERT INTO loyalty_points
+		(id, profile_id, booking_id, points)
+		VALUES
+		($1, $2, $3, $4)
+		ON CONFLICT (profile_id, booking_id) DO NOTHING`,
+		points.Id, points.ProfileId, points.BookingId, points.Points)
+	return err
+}
+
+// GetBalances returns point balances of given passengers, passengers without points are omitted
+func (l *loyaltyRepository) GetBalances(profileIds []string) (map[string]int64, error) {
+	rows, err := l.db.Query(`SELECT profile_id, SUM(points) FROM loyalty_points WHERE profile_id = ANY($1)
+		GROUP BY profile_id`, pq.Array(profileIds))
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]int64, len(profileIds))
+	for rows.Next() {
+		profileId := ""
+		balance := int64(0)
+		if err := rows.Scan(&profileId, &balance); err != nil {
+			return balances, err
+		}
+		balances[profileId] = balance
+	}
+	return balances, nil
+}
+
+// GetFlights returns flown bookings of the passenger ordered by launch date
+func (l *loyaltyRepository) GetFlights(profileId string) ([]FlightHistoryItem, error) {
+	rows, err := l.db.Query(`SELECT b.id, b.launch_date, d.id, d.name, d.distance_km, COALESCE(lp.points, 0)
+		FROM booking_passenger p
+			JOIN booking b ON b.id = p.booking_id
+			JOIN destination d ON d.id = b.destination_id
+			LEFT JOIN loyalty_points lp ON lp.booking_id = b.id AND lp.profile_id = p.profile_id
+		WHERE p.profile_id = $1 AND b.status = $2
+		ORDER BY b.launch_date, b.id`, profileId, FLOWN)
+	if err != nil {
+		return nil, err
+	}
+	flights := make([]FlightHistoryItem, 0, 1)
+	for rows.Next() {
+		flight := FlightHistoryItem{}
+		if err := rows.Scan(&flight.BookingId, &flight.LaunchDate, &flight.DestinationId, &flight.Destination,
+			&flight.DistanceKm, &flight.Points); err != nil {
+			return flights, err
+		}
+		flights = append(flights, flight)
+	}
+	return flights, nil
+}
+
+// NewPaymentRepository creates new payment repository
+func NewPaymentRepository(db *sql.DB) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+// Add adds new payment transaction
+func (p *paymentRepository) Add(transaction *PaymentTransaction) error {
+	row := p.db.QueryRow(`INSERT INTO payment_transaction
+		(id, booking_id, type, reference, amount, currency, status, message)
+		VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at`,
+		transaction.Id, transaction.BookingId, transaction.Type, transaction.Reference, transaction.Amount,
+		transaction.Currency, transaction.Status, transaction.Message)
+	return row.Scan(&transaction.CreatedAt)
+}
+
+// GetAllForBooking returns payment transactions of the booking in order they were made
+func (p *paymentRepository) GetAllForBooking(bookingId string) ([]PaymentTransaction, error) {
+	rows, err := p.db.Query(`SELECT id, booking_id, type, reference, amount, currency, status, message, created_at
+		FROM payment_transaction WHERE booking_id = $1 ORDER BY created_at, id`, bookingId)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]PaymentTransaction, 0, 1)
+	for rows.Next() {
+		transaction := PaymentTransaction{}
+		if err := rows.Scan(&transaction.Id, &transaction.BookingId, &transaction.Type, &transaction.Reference,
+			&transaction.Amount, &transaction.Currency, &transaction.Status, &transaction.Message,
+			&transaction.CreatedAt); err != nil {
+			return transactions, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+const refundSelect = `SELECT booking_id, percent, amount, attempts, refunded_at FROM pending_refund`
+
+// AddRefundTx adds pending refund of given percent of the price captured for the booking, nothing is added
+// if nothing is captured or refund of the booking is already added
+func (p *paymentRepository) AddRefundTx(tx *sql.Tx, bookingId string, percent int) error {
+	_, err := tx.Exec(`INSERT INTO pending_refund (booking_id, percent)
+		SELECT $1, $2 WHERE EXISTS (
+			SELECT 1 FROM payment_transaction WHERE booking_id = $1 AND type = 'capture' AND status = 'succeeded')
+		ON CONFLICT (booking_id) DO NOTHING`, bookingId, percent)
+	return err
+}
+
+// GetRefundForUpdateTx returns refund of the booking locked until the end of the transaction
+func (p *paymentRepository) GetRefundForUpdateTx(tx *sql.Tx, bookingId string) (*PendingRefund, error) {
+	return scanRefund(tx.QueryRow(refundSelect+" WHERE booking_id = $1 FOR UPDATE", bookingId))
+}
+
+// ClaimRefundsTx returns at most limit refunds which are due, refunds are locked until the end of the given
+// transaction and are skipped by concurrent workers
+func (p *paymentRepository) ClaimRefundsTx(tx *sql.Tx, limit int) ([]PendingRefund, error) {
+	rows, err := tx.Query(refundSelect+` WHERE refunded_at IS NULL AND next_attempt_at <= now()
+		ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return nil, err
+	}
+	refunds := make([]PendingRefund, 0, 1)
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return refunds, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds, nil
+}
+
+func scanRefund(row interface{ Scan(...interface{}) error }) (*PendingRefund, error) {
+	refund := PendingRefund{}
+	err := row.Scan(&refund.BookingId, &refund.Percent, &refund.Amount, &refund.Attempts, &refund.RefundedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// MarkRefundedTx records that refund of the booking is made
+func (p *paymentRepository) MarkRefundedTx(tx *sql.Tx, bookingId string, amount int64) error {
+	_, err := tx.Exec(`UPDATE pending_refund SET refunded_at = now(), amount = $2, last_error = NULL
+		WHERE booking_id = $1`, bookingId, amount)
+	return err
+}
+
+// MarkRefundFailedTx records failure to make refund of the booking, it is retried after retryIn
+func (p *paymentRepository) MarkRefundFailedTx(tx *sql.Tx, bookingId string, reason string,
+	retryIn time.Duration) error {
+	_, err := tx.Exec(`UPDATE pending_refund SET attempts = attempts + 1, last_error = $2,
+		next_attempt_at = now() + make_interval(secs => $3) WHERE booking_id = $1`, bookingId, reason,
+		retryIn.Seconds())
+	return err
+}
+
+// NewPromoRepository creates new promo code repository
+func NewPromoRepository(db *sql.DB) PromoRepository {
+	return &promoRepository{db: db}
+}
+
+// Add adds new promo code, returns false if code already exists
+func (p *promoRepository) Add(promo *PromoCode) (bool, error) {
+	row := p.db.QueryRow(`INSERT INTO promo_code
+		(code, description, percent_off, amount_off, valid_from, valid_to, launch_date_from, launch_date_to,
+		destination_id, launchpad_id, max_uses, stackable)
+		VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING active, created_at`,
+		promo.Code, promo.Description, promo.PercentOff, promo.AmountOff, promo.ValidFrom, promo.ValidTo,
+		nullDate(promo.LaunchDateFrom), nullDate(promo.LaunchDateTo), promo.DestinationId, promo.LaunchpadId,
+		promo.MaxUses, promo.Stackable)
+	err := row.Scan(&promo.Active, &promo.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns promo code
+func (p *promoRepository) Get(code string) (*PromoCode, error) {
+	report, err := p.GetReport(code)
+	if err != nil {
+		return nil, err
+	}
+	return &report.PromoCode, nil
+}
+
+// GetAll returns all promo codes with their usage, newest first
+func (p *promoRepository) GetAll() ([]PromoCodeReport, error) {
+	rows, err := p.db.Query(promoSelect + ` ORDER BY p.created_at DESC, p.code`)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]PromoCodeReport, 0, 10)
+	for rows.Next() {
+		report, err := scanPromo(rows)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// GetReport returns promo code with its usage
+func (p *promoRepository) GetReport(code string) (*PromoCodeReport, error) {
+	return scanPromo(p.db.QueryRow(promoSelect+` WHERE p.code = $1`, code))
+}
+
+// GetRedemptions returns all redemptions of the promo code in order they were made
+func (p *promoRepository) GetRedemptions(code string) ([]PromoRedemption, error) {
+	rows, err := p.db.Query(`SELECT id, code, booking_id, amount, released, created_at
+		FROM promo_redemption WHERE code = $1 ORDER BY created_at, id`, code)
+	if err != nil {
+		return nil, err
+	}
+	redemptions := make([]PromoRedemption, 0, 10)
+	for rows.Next() {
+		redemption := PromoRedemption{}
+		if err := rows.Scan(&redemption.Id, &redemption.Code, &redemption.BookingId, &redemption.Amount,
+			&redemption.Released, &redemption.CreatedAt); err != nil {
+			return redemptions, err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	return redemptions, nil
+}
+
+// Deactivate deactivates promo code, returns false if code does not exist
+func (p *promoRepository) Deactivate(code string) (bool, error) {
+	result, err := p.db.Exec(`UPDATE promo_code SET active = FALSE WHERE code = $1`, code)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RedeemTx records redemption of the promo code, returns false if code is inactive or usage limit is reached
+func (p *promoRepository) RedeemTx(tx *sql.Tx, redemption *PromoRedemption) (bool, error) {
+	result, err := tx.Exec(`UPDATE promo_code SET uses = uses + 1
+		WHERE code = $1 AND active AND (max_uses IS NULL OR uses < max_uses)`, redemption.Code)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	row := tx.QueryRow(`INSERT INTO promo_redemption
+		(id, code, booking_id, amount)
+		VALUES
+		($1, $2, $3, $4)
+		RETURNING created_at`,
+		redemption.Id, redemption.Code, redemption.BookingId, redemption.Amount)
+	err = row.Scan(&redemption.CreatedAt)
+	return err == nil, err
+}
+
+// ReleaseTx releases redemptions of the booking, so codes can be used again
+func (p *promoRepository) ReleaseTx(tx *sql.Tx, bookingId string) error {
+	_, err := tx.Exec(`WITH released AS (
+			UPDATE promo_redemption SET released = TRUE WHERE booking_id = $1 AND NOT released RETURNING code
+		)
+		UPDATE promo_code p SET uses = p.uses - (SELECT COUNT(*) FROM released r WHERE r.code = p.code)
+		WHERE p.code IN (SELECT code FROM released)`, bookingId)
+	return err
+}
+
+func scanPromo(row interface{ Scan(...interface{}) error }) (*PromoCodeReport, error) {
+	report := PromoCodeReport{}
+	var validFrom, validTo, launchDateFrom, launchDateTo sql.NullTime
+	var destinationId, launchpadId sql.NullString
+	var maxUses sql.NullInt32
+	err := row.Scan(&report.Code, &report.Description, &report.PercentOff, &report.AmountOff, &validFrom, &validTo,
+		&launchDateFrom, &launchDateTo, &destinationId, &launchpadId, &maxUses, &report.Uses, &report.Stackable,
+		&report.Active, &report.CreatedAt, &report.TotalDiscount)
+	if err != nil {
+		return nil, err
+	}
+	if validFrom.Valid {
+		report.ValidFrom = &validFrom.Time
+	}
+	if validTo.Valid {
+		report.ValidTo = &validTo.Time
+	}
+	if launchDateFrom.Valid {
+		date := Date(launchDateFrom.Time)
+		report.LaunchDateFrom = &date
+	}
+	if launchDateTo.Valid {
+		date := Date(launchDateTo.Time)
+		report.LaunchDateTo = &date
+	}
+	if destinationId.Valid {
+		report.DestinationId = &destinationId.String
+	}
+	if launchpadId.Valid {
+		report.LaunchpadId = &launchpadId.String
+	}
+	if maxUses.Valid {
+		uses := int(maxUses.Int32)
+		report.MaxUses = &uses
+	}
+	return &report, nil
+}
+
+// nullDate converts missing date to SQL NULL
+func nullDate(date *Date) interface{} {
+	if date == nil {
+		return nil
+	}
+	return time.Time(*date)
+}
+
+// nullJson converts empty JSON to SQL NULL
+func nullJson(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
 // exists is an utility function to check if record exists
 func exists(row *sql.Row) (bool, error) {
 	exists := false
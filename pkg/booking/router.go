@@ -0,0 +1,146 @@
+package booking
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// params holds values of path parameters of the matched route, e.g. id of /booking/{id}
+type params map[string]string
+
+// handlerFunc handles request matched by the route
+type handlerFunc func(w http.ResponseWriter, r *http.Request, p params)
+
+type route struct {
+	method   string
+	segments []string
+	handler  handlerFunc
+}
+
+// router dispatches requests by method and path pattern, pattern segments in braces are path parameters.
+// Trailing slashes and query strings are ignored, literal segments take precedence over parameters,
+// so /passenger/duplicates is matched before /passenger/{id}
+type router struct {
+	routes           []route
+	notFound         http.HandlerFunc
+	methodNotAllowed func(w http.ResponseWriter, r *http.Request, allowed []string)
+}
+
+func newRouter(notFound http.HandlerFunc,
+	methodNotAllowed func(w http.ResponseWriter, r *http.Request, allowed []string)) *router {
+	return &router{notFound: notFound, methodNotAllowed: methodNotAllowed}
+}
+
+// handle adds route for given method and pattern, GET routes serve HEAD requests as well
+func (rt *router) handle(method string, pattern string, handler handlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+		w = headResponseWriter{w}
+	}
+
+	var matched *route
+	var matchedParams params
+	allowed := make(map[string]bool)
+	for i := range rt.routes {
+		p, ok := rt.routes[i].match(segments)
+		if !ok {
+			continue
+		}
+		allowed[rt.routes[i].method] = true
+		if rt.routes[i].method == method && (matched == nil || rt.routes[i].moreSpecificThan(matched)) {
+			matched = &rt.routes[i]
+			matchedParams = p
+		}
+	}
+	switch {
+	case len(allowed) == 0:
+		rt.notFound(w, r)
+	case r.Method == http.MethodOptions:
+		w.Header().Set("Allow", strings.Join(allowedMethods(allowed), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	case matched == nil:
+		methods := allowedMethods(allowed)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		rt.methodNotAllowed(w, r, methods)
+	default:
+		matched.handler(w, r, matchedParams)
+	}
+}
+
+// match checks that path segments match the route and returns values of path parameters
+func (rt *route) match(segments []string) (params, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	p := params{}
+	for i, segment := range rt.segments {
+		if isParam(segment) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			p[segment[1:len(segment)-1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return p, true
+}
+
+// moreSpecificThan checks whether the route has literal segment where other route has parameter,
+// both routes are expected to match the same path
+func (rt *route) moreSpecificThan(other *route) bool {
+	for i, segment := range rt.segments {
+		literal, otherLiteral := !isParam(segment), !isParam(other.segments[i])
+		if literal != otherLiteral {
+			return literal
+		}
+	}
+	return false
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// splitPath splits path into segments, leading and trailing slashes are ignored
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// allowedMethods returns sorted methods of the matched routes along with HEAD and OPTIONS
+func allowedMethods(allowed map[string]bool) []string {
+	methods := make([]string, 0, len(allowed)+2)
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	if allowed[http.MethodGet] {
+		methods = append(methods, http.MethodHead)
+	}
+	methods = append(methods, http.MethodOptions)
+	sort.Strings(methods)
+	return methods
+}
+
+// headResponseWriter discards body of the response to HEAD request
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
@@ -0,0 +1,80 @@
+package booking
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testRouter creates router which writes name of the matched route along with its path parameters
+func testRouter() *router {
+	rt := newRouter(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, func(w http.ResponseWriter, r *http.Request, allowed []string) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	named := func(name string) handlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p params) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, name+" "+p["id"])
+		}
+	}
+	rt.handle(http.MethodGet, "/booking", named("getBookings"))
+	rt.handle(http.MethodPost, "/booking", named("addBooking"))
+	rt.handle(http.MethodGet, "/booking/{id}", named("getBooking"))
+	rt.handle(http.MethodDelete, "/booking/{id}", named("deleteBooking"))
+	rt.handle(http.MethodGet, "/passenger/{id}", named("getPassenger"))
+	rt.handle(http.MethodGet, "/passenger/duplicates", named("getDuplicatePassengers"))
+	rt.handle(http.MethodGet, "/launch", named("getLaunches"))
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		target string
+		status int
+		allow  string
+		body   string
+	}{
+		{"route", http.MethodGet, "/booking", http.StatusOK, "", "getBookings "},
+		{"method of the same route", http.MethodPost, "/booking", http.StatusOK, "", "addBooking "},
+		{"path parameter", http.MethodGet, "/booking/42", http.StatusOK, "", "getBooking 42"},
+		{"trailing slash", http.MethodGet, "/booking/", http.StatusOK, "", "getBookings "},
+		{"trailing slash after parameter", http.MethodDelete, "/booking/42/", http.StatusOK, "", "deleteBooking 42"},
+		{"query string", http.MethodGet, "/booking/?x=1", http.StatusOK, "", "getBookings "},
+		{"query string after parameter", http.MethodGet, "/booking/42?x=1", http.StatusOK, "", "getBooking 42"},
+		{"literal before parameter", http.MethodGet, "/passenger/duplicates", http.StatusOK, "",
+			"getDuplicatePassengers "},
+		{"parameter next to literal", http.MethodGet, "/passenger/42", http.StatusOK, "", "getPassenger 42"},
+		{"unknown path", http.MethodGet, "/unknown", http.StatusNotFound, "", ""},
+		{"too many segments", http.MethodGet, "/booking/42/extra", http.StatusNotFound, "", ""},
+		{"several trailing slashes", http.MethodGet, "/booking//", http.StatusOK, "", "getBookings "},
+		{"unknown method", http.MethodPut, "/booking/42", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS",
+			""},
+		{"put on get-only route", http.MethodPut, "/launch", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"post on get-only route with query", http.MethodPost, "/launch/?x=1", http.StatusMethodNotAllowed,
+			"GET, HEAD, OPTIONS", ""},
+		{"head", http.MethodHead, "/booking/42", http.StatusOK, "", ""},
+		{"head of unknown path", http.MethodHead, "/unknown", http.StatusNotFound, "", ""},
+		{"options", http.MethodOptions, "/booking", http.StatusNoContent, "GET, HEAD, OPTIONS, POST", ""},
+		{"options of get-only route", http.MethodOptions, "/launch/", http.StatusNoContent, "GET, HEAD, OPTIONS", ""},
+		{"options of unknown path", http.MethodOptions, "/unknown", http.StatusNotFound, "", ""},
+	}
+	rt := testRouter()
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		rt.ServeHTTP(recorder, httptest.NewRequest(c.method, c.target, nil))
+		if recorder.Code != c.status {
+			t.Errorf("%s: expected %d for %s %s, got %d", c.name, c.status, c.method, c.target, recorder.Code)
+		}
+		if allow := recorder.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("%s: expected Allow %q for %s %s, got %q", c.name, c.allow, c.method, c.target, allow)
+		}
+		if body := recorder.Body.String(); body != c.body {
+			t.Errorf("%s: expected body %q for %s %s, got %q", c.name, c.body, c.method, c.target, body)
+		}
+	}
+}
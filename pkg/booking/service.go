@@ -2,54 +2,180 @@ package booking
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yosadchyi/space-booking/pkg/notification"
+	"github.com/yosadchyi/space-booking/pkg/payment"
 	"github.com/yosadchyi/space-booking/pkg/spacex"
 )
 
+// SystemActor is an actor recorded in audit log for changes made by the service itself
+const SystemActor = "system"
+
+// refundBatchSize is a maximum number of pending refunds made in one transaction
+const refundBatchSize = 100
+
+// waitlistBatchSize is a maximum number of freed seats claimed to be offered to the waitlist at once
+const waitlistBatchSize = 100
+
+// waitlistLease is a time freed seats are hidden from other workers while the waitlist is processed
+const waitlistLease = time.Minute
+
+// errWaitlistEntryNotWaiting is returned if waitlist entry is booked concurrently
+var errWaitlistEntryNotWaiting = errors.New("waitlist entry is not waiting anymore")
+
+// Config is a service configuration
+type Config struct {
+	// HoldTTL is a time seats are held for before hold is released
+	HoldTTL time.Duration
+	// Pricing is a policy used to calculate price of the booking
+	Pricing PricingPolicy
+	// Cancellation is a policy used to calculate refund of the cancelled booking
+	Cancellation CancellationPolicy
+	// Loyalty is a policy used to accrue frequent-flyer points
+	Loyalty LoyaltyPolicy
+	// PaymentProvider is used to charge bookings
+	PaymentProvider payment.Provider
+	// Webhook is a policy used to deliver events to webhook subscriptions
+	Webhook WebhookPolicy
+	// EventSinks receive events relayed from the outbox along with webhook subscriptions and in-process subscribers
+	EventSinks []EventSink
+	// Notifier is used to email customers about their bookings, customers are not notified if it is nil
+	Notifier notification.Notifier
+	// AdminToken is a bearer token required by admin routes, admin routes are disabled if it is empty
+	AdminToken string
+	// NoShowWindow is a time after launch date admins have to mark bookings as no-show, confirmed bookings are
+	// marked as flown after it
+	NoShowWindow time.Duration
+}
+
+// DefaultConfig returns default service configuration
+func DefaultConfig() Config {
+	return Config{
+		HoldTTL:         15 * time.Minute,
+		NoShowWindow:    7 * 24 * time.Hour,
+		Pricing:         DefaultPricingPolicy(),
+		Cancellation:    DefaultCancellationPolicy(),
+		Loyalty:         DefaultLoyaltyPolicy(),
+		PaymentProvider: payment.NewFakeProvider(),
+		Webhook:         DefaultWebhookPolicy(),
+		EventSinks:      []EventSink{LogSink{}},
+		Notifier:        notification.NewLogNotifier(),
+	}
+}
+
 // Service is an entity representing business logic
 type Service struct {
+	config                Config
 	db                    *sql.DB
 	launchpadRepository   LaunchpadRepository
 	launchRepository      LaunchRepository
 	destinationRepository DestinationRepository
+	genderRepository      GenderRepository
 	importer              DataImporter
 	mainRepository        MainRepository
+	waitlistRepository    WaitlistRepository
+	auditRepository       AuditRepository
+	eventRepository       EventRepository
+	outboxRepository      OutboxRepository
+	webhookRepository     WebhookRepository
+	paymentRepository     PaymentRepository
+	promoRepository       PromoRepository
+	passengerRepository   PassengerRepository
+	loyaltyRepository     LoyaltyRepository
+	fareCalculator        FareCalculator
+	paymentProvider       payment.Provider
+	webhookSender         *webhookSender
+	subscribers           *eventSubscribers
+	eventSinks            []EventSink
 }
 
 // NewService returns new service, creates internal dependencies
-func NewService(db *sql.DB) *Service {
+func NewService(db *sql.DB, config Config) *Service {
 	spaceXClient := spacex.NewClient()
 	launchpadRepository := NewLaunchpadRepository(db)
 	launchRepository := NewLaunchRepository(db)
+	destinationRepository := NewDestinationRepository(db)
+	webhookRepository := NewWebhookRepository(db)
+	auditRepository := NewAuditRepository(db)
+	subscribers := newEventSubscribers()
+	eventSinks := append([]EventSink{}, config.EventSinks...)
+	eventSinks = append(eventSinks, &webhookSink{webhookRepository: webhookRepository}, subscribers)
+	if config.Notifier != nil {
+		// emails are not idempotent, they are sent last and are repeated only if relay stops before recording them
+		eventSinks = append(eventSinks, &notificationSink{
+			notifier:              config.Notifier,
+			launchpadRepository:   launchpadRepository,
+			destinationRepository: destinationRepository,
+			auditRepository:       auditRepository,
+		})
+	}
 
-	return &Service{
+	service := &Service{
+		config:                config,
 		db:                    db,
 		launchpadRepository:   launchpadRepository,
 		launchRepository:      launchRepository,
-		destinationRepository: NewDestinationRepository(db),
-		importer:              NewDataImporter(spaceXClient, launchpadRepository, launchRepository),
+		destinationRepository: destinationRepository,
 		mainRepository:        NewMainRepository(db),
+		waitlistRepository:    NewWaitlistRepository(db),
+		auditRepository:       auditRepository,
+		eventRepository:       NewEventRepository(db),
+		outboxRepository:      NewOutboxRepository(db),
+		webhookRepository:     webhookRepository,
+		paymentRepository:     NewPaymentRepository(db),
+		promoRepository:       NewPromoRepository(db),
+		passengerRepository:   NewPassengerRepository(db),
+		genderRepository:      NewGenderRepository(db),
+		loyaltyRepository:     NewLoyaltyRepository(db),
+		fareCalculator:        NewFareCalculator(config.Pricing, destinationRepository, launchpadRepository),
+		paymentProvider:       config.PaymentProvider,
+		webhookSender:         newWebhookSender(config.Webhook.Timeout),
+		subscribers:           subscribers,
+		eventSinks:            eventSinks,
 	}
+	service.importer = NewDataImporter(spaceXClient, launchpadRepository, launchRepository, service)
+	return service
 }
 
 // Init initializes service
 func (s *Service) Init() {
+	err := s.Import()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Import imports launchpads and upcoming launches, launchpads freed by removed launches are recorded to be offered
+// to the waitlist by ProcessWaitlist
+func (s *Service) Import() error {
 	log.Println("importing launchpad data...")
 	err := s.importer.ImportLaunchpads()
 	if err != nil {
-		log.Fatalf("error importing launchpad data: %s", err)
+		return fmt.Errorf("error importing launchpad data: %w", err)
 	}
 
 	log.Println("importing upcoming launches data...")
-	err = s.importer.ImportUpcomingSpaceXLaunches()
+	removed, err := s.importer.ImportUpcomingSpaceXLaunches()
 	if err != nil {
-		log.Fatalf("error importing upcoming launches: %s", err)
+		return fmt.Errorf("error importing upcoming launches: %w", err)
 	}
 
 	log.Println("data import finished successfully")
+	for _, launch := range removed {
+		err = s.waitlistRepository.AddFreedSeats(launch.LaunchpadId, launch.Date)
+		if err != nil {
+			return fmt.Errorf("can't offer launchpad freed by removed launch to the waitlist: %w", err)
+		}
+	}
+	return nil
 }
 
 // GetAllLaunchpads returns all launchpads
@@ -62,115 +188,521 @@ func (s *Service) GetAllDestinations() (AllDestinationsResponse, error) {
 	return s.destinationRepository.GetAll()
 }
 
+// GetAllGenders returns all allowed genders of passengers
+func (s *Service) GetAllGenders() (AllGendersResponse, error) {
+	return s.genderRepository.GetAll()
+}
+
 // GetAllBookings returns all bookings
 func (s *Service) GetAllBookings() (AllBookingsResponse, error) {
 	return s.mainRepository.GetAll()
 }
 
-// DeleteBooking deletes booking and related launch
-func (s *Service) DeleteBooking(id string) error {
-	tx, _ := s.db.Begin()
-	err := s.mainRepository.DeleteTx(tx, id)
+// GetAllLaunches returns all upcoming launches along with remaining seats
+func (s *Service) GetAllLaunches() (AllLaunchesResponse, error) {
+	return s.launchRepository.GetAllUpcoming()
+}
+
+// GetBooking returns booking by its id, cancelled bookings are returned as well
+func (s *Service) GetBooking(id string) (*Booking, error) {
+	booking, err := s.mainRepository.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, newError(CodeBookingNotFound, "booking does not exists")
+	}
 	if err != nil {
-		_ = tx.Rollback()
-		return err
+		return nil, err
+	}
+	return booking, nil
+}
+
+// GetBookingHistory returns audit log of the booking
+func (s *Service) GetBookingHistory(id string) (BookingHistoryResponse, error) {
+	_, err := s.mainRepository.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, newError(CodeBookingNotFound, "booking does not exists")
 	}
-	err = s.launchRepository.Delete(tx, id)
 	if err != nil {
-		_ = tx.Rollback()
-		return err
+		return nil, err
 	}
-	return tx.Commit()
+	entries, err := s.auditRepository.GetAllForBooking(id)
+	if err != nil {
+		return nil, err
+	}
+	return BookingHistoryResponse(entries), nil
 }
 
-// AddBooking adds booking
-func (s *Service) AddBooking(request Request) (interface{}, error) {
-	if time.Time(request.LaunchDate).Before(time.Now()) {
-		return &ErrorResponse{
-			Code:    "LAUNCH_DATE_IS_IN_PAST",
-			Message: "launch date is in past",
-		}, nil
+// AddPromoCode adds new promo code
+func (s *Service) AddPromoCode(request PromoCodeRequest) (*SuccessResponse, error) {
+	promo := PromoCode{
+		Code:           normalizePromoCode(request.Code),
+		Description:    request.Description,
+		PercentOff:     request.PercentOff,
+		AmountOff:      request.AmountOff,
+		ValidFrom:      request.ValidFrom,
+		ValidTo:        request.ValidTo,
+		LaunchDateFrom: request.LaunchDateFrom,
+		LaunchDateTo:   request.LaunchDateTo,
+		DestinationId:  request.DestinationId,
+		LaunchpadId:    request.LaunchpadId,
+		MaxUses:        request.MaxUses,
+		Stackable:      request.Stackable,
 	}
-	active, err := s.launchpadRepository.ExistsAndIsActive(request.LaunchpadId)
+	if err := validatePromoCode(&promo); err != nil {
+		return nil, err
+	}
+	if promo.DestinationId != nil {
+		exists, err := s.destinationRepository.Exists(*promo.DestinationId)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, newError(CodeDestinationIsInvalid, "destination does not exists")
+		}
+	}
+	if promo.LaunchpadId != nil {
+		_, err := s.launchpadRepository.Get(*promo.LaunchpadId)
+		if err == sql.ErrNoRows {
+			return nil, newError(CodeLaunchpadIsInvalid, "launchpad does not exists")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	added, err := s.promoRepository.Add(&promo)
 	if err != nil {
 		return nil, err
 	}
-	if !active {
-		return &ErrorResponse{
-			Code:    "LAUNCHPAD_NOT_AVAILABLE",
-			Message: "launchpad does not exists or is inactive",
-		}, nil
+	if !added {
+		return nil, newError(CodePromoCodeAlreadyExists, "promo code %s already exists", promo.Code)
+	}
+	return &SuccessResponse{Id: promo.Code}, nil
+}
+
+// GetAllPromoCodes returns all promo codes along with their usage
+func (s *Service) GetAllPromoCodes() (AllPromoCodesResponse, error) {
+	return s.promoRepository.GetAll()
+}
+
+// GetPromoCode returns promo code along with its usage and redemptions
+func (s *Service) GetPromoCode(code string) (*PromoCodeResponse, error) {
+	report, err := s.promoRepository.GetReport(normalizePromoCode(code))
+	if err == sql.ErrNoRows {
+		return nil, promoCodeNotFound(code)
 	}
-	exists, err := s.destinationRepository.Exists(request.DestinationId)
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
-		return &ErrorResponse{
-			Code:    "DESTINATION_IS_INVALID",
-			Message: "destination does not exists",
-		}, nil
+	redemptions, err := s.promoRepository.GetRedemptions(report.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &PromoCodeResponse{
+		PromoCodeReport: *report,
+		Redemptions:     redemptions,
+	}, nil
+}
+
+// DeactivatePromoCode deactivates promo code, it can't be applied to new bookings anymore
+func (s *Service) DeactivatePromoCode(code string) (*SuccessResponse, error) {
+	code = normalizePromoCode(code)
+	deactivated, err := s.promoRepository.Deactivate(code)
+	if err != nil {
+		return nil, err
+	}
+	if !deactivated {
+		return nil, promoCodeNotFound(code)
+	}
+	return &SuccessResponse{Id: code}, nil
+}
+
+func promoCodeNotFound(code string) error {
+	return newError(CodePromoCodeNotFound, "promo code %s does not exists", code)
+}
+
+// GetAllPassengers returns all passenger profiles
+func (s *Service) GetAllPassengers() (AllPassengersResponse, error) {
+	return s.passengerRepository.GetAll()
+}
+
+// GetPassenger returns passenger profile
+func (s *Service) GetPassenger(id string) (*PassengerProfile, error) {
+	return s.getPassenger(id)
+}
+
+// AddPassenger adds new passenger profile
+func (s *Service) AddPassenger(request PassengerProfileRequest) (*SuccessResponse, error) {
+	if err := validatePassenger(request); err != nil {
+		return nil, err
 	}
 	newUUID, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
 	}
+	profile := PassengerProfile{
+		Id:        newUUID.String(),
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Gender:    request.Gender,
+		Birthday:  request.Birthday,
+	}
+	err = s.passengerRepository.Add(&profile)
+	if err != nil {
+		return nil, err
+	}
+	return &SuccessResponse{Id: profile.Id}, nil
+}
 
-	tx, _ := s.db.Begin()
-	launches, err := s.launchRepository.GetAllFromLaunchpadAtDate(request.LaunchpadId, request.LaunchDate)
+// UpdatePassenger updates passenger profile, passengers of existing bookings are kept as they were booked
+func (s *Service) UpdatePassenger(id string, request PassengerProfileRequest) (*PassengerProfile, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, passengerNotFound(id)
+	}
+	if err := validatePassenger(request); err != nil {
+		return nil, err
+	}
+	profile := PassengerProfile{
+		Id:        id,
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Gender:    request.Gender,
+		Birthday:  request.Birthday,
+	}
+	updated, err := s.passengerRepository.Update(&profile)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, passengerNotFound(id)
+	}
+	return &profile, nil
+}
 
+// DeletePassenger deletes passenger profile which has no bookings
+func (s *Service) DeletePassenger(id string) (*SuccessResponse, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, passengerNotFound(id)
+	}
+	hasBookings, err := s.passengerRepository.HasBookings(id)
 	if err != nil {
 		return nil, err
 	}
-	if len(launches) > 0 {
+	if hasBookings {
+		return nil, newError(CodePassengerHasBookings, "passenger with bookings can't be deleted")
+	}
+	deleted, err := s.passengerRepository.Delete(id)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, passengerNotFound(id)
+	}
+	return &SuccessResponse{Id: id}, nil
+}
+
+// GetPassengerBookings returns all bookings of the passenger
+func (s *Service) GetPassengerBookings(id string) (AllBookingsResponse, error) {
+	_, err := s.getPassenger(id)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := s.mainRepository.GetAllForPassenger(id)
+	if err != nil {
+		return nil, err
+	}
+	return AllBookingsResponse(bookings), nil
+}
+
+// GetPassengerLoyalty returns flight history of the passenger along with point balance and reached tier
+func (s *Service) GetPassengerLoyalty(id string) (*LoyaltyResponse, error) {
+	_, err := s.getPassenger(id)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.loyaltyRepository.GetBalances([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	flights, err := s.loyaltyRepository.GetFlights(id)
+	if err != nil {
+		return nil, err
+	}
+	response := LoyaltyResponse{
+		PassengerId: id,
+		Points:      balances[id],
+		Flights:     flights,
+	}
+	tier, next := s.config.Loyalty.Tier(response.Points)
+	if tier != nil {
+		response.Tier = tier.Name
+		response.PriorityWaitlist = tier.PriorityWaitlist
+	}
+	if next != nil {
+		response.NextTier = next.Name
+		response.PointsToNextTier = next.MinPoints - response.Points
+	}
+	return &response, nil
+}
+
+// GetDuplicatePassengers returns groups of passenger profiles having the same name and birthday
+func (s *Service) GetDuplicatePassengers() (PassengerDuplicatesResponse, error) {
+	return s.passengerRepository.GetDuplicates()
+}
+
+// MergePassengers merges duplicate passenger profiles into the profile with given id, duplicates should have
+// the same name and birthday. Their bookings and waitlist entries are moved to that profile and duplicates are deleted
+func (s *Service) MergePassengers(id string, request MergeRequest) (*SuccessResponse, error) {
+	profile, err := s.getPassenger(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.PassengerIds) == 0 {
+		return nil, newError(CodeNoPassengers, "at least one passenger to merge is required")
+	}
+	for _, duplicateId := range request.PassengerIds {
+		if duplicateId == id {
+			return nil, newError(CodePassengerIsInvalid, "passenger can't be merged into itself")
+		}
+		duplicate, err := s.getPassenger(duplicateId)
+		if isDomainError(err) {
+			return nil, newError(CodePassengerIsInvalid, "passenger %s does not exists", duplicateId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !sameProfileIdentity(*profile, *duplicate) {
+			return nil, newError(CodePassengerIsInvalid, "passenger %s has different name or birthday", duplicateId)
+		}
+	}
+	tx, _ := s.db.Begin()
+	err = s.passengerRepository.MergeTx(tx, id, request.PassengerIds)
+	if err != nil {
 		_ = tx.Rollback()
-		return &ErrorResponse{
-			Code:    "LAUNCHPAD_BUSY",
-			Message: "launchpad is busy at given date",
-		}, nil
+		return nil, err
 	}
-	booking := Booking{
-		Id:            newUUID.String(),
-		FirstName:     request.FirstName,
-		LastName:      request.LastName,
-		Gender:        request.Gender,
-		Birthday:      request.Birthday,
-		LaunchpadId:   request.LaunchpadId,
-		DestinationId: request.DestinationId,
-		LaunchDate:    request.LaunchDate,
-		LaunchId:      newUUID.String(), // for simplicity use same id for launch as for booking
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &SuccessResponse{Id: id}, nil
+}
+
+func (s *Service) getPassenger(id string) (*PassengerProfile, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, passengerNotFound(id)
+	}
+	profile, err := s.passengerRepository.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, passengerNotFound(id)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return profile, nil
+}
 
-	weekLaunches, err := s.launchRepository.GetWeekLaunches(tx, booking.LaunchpadId, booking.LaunchDate)
+func validatePassenger(request PassengerProfileRequest) error {
+	if strings.TrimSpace(request.FirstName) == "" || strings.TrimSpace(request.LastName) == "" {
+		return newError(CodePassengerIsInvalid, "first name and last name are required")
+	}
+	return nil
+}
+
+func passengerNotFound(id string) error {
+	return newError(CodePassengerNotFound, "passenger %s does not exists", id)
+}
+
+// DeleteBooking cancels booking and refunds part of the paid price according to cancellation policy. Refund which
+// fails is retried in background, request to delete already cancelled booking retries it immediately and returns
+// the same cancellation, even if the booking is cancelled by concurrent request
+func (s *Service) DeleteBooking(actor string, id string) (*CancellationResponse, error) {
+	booking, err := s.mainRepository.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, newError(CodeBookingNotFound, "booking does not exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status != CANCELLED {
+		_, err = s.ChangeBookingStatus(actor, id, CANCELLED)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.refundCancelled(booking)
+}
+
+// refundCancelled makes pending refund of the cancelled booking unless it is already made, refunded part
+// of the price is returned
+func (s *Service) refundCancelled(booking *Booking) (*CancellationResponse, error) {
+	response := CancellationResponse{
+		Id:            booking.Id,
+		RefundPercent: s.refundPercent(booking),
+		Currency:      booking.Currency,
+	}
+	tx, _ := s.db.Begin()
+	refund, err := s.paymentRepository.GetRefundForUpdateTx(tx, booking.Id)
+	if err == sql.ErrNoRows {
+		// nothing is captured for the booking
+		_ = tx.Rollback()
+		return &response, nil
+	}
 	if err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
-	for _, launch := range weekLaunches {
-		id, err := s.mainRepository.GetDestinationIdForBookingId(tx, launch.Id)
-		switch {
-		case err == sql.ErrNoRows:
-			continue // skip SpaceX launch
-		case err != nil:
+	if refund.RefundedAt != nil {
+		_ = tx.Rollback()
+	} else {
+		refundErr := s.refundTx(tx, refund)
+		err = tx.Commit()
+		if refundErr != nil {
+			return nil, fmt.Errorf("booking is cancelled, refund will be retried: %w", refundErr)
+		}
+		if err != nil {
 			return nil, err
 		}
-		if request.DestinationId == id {
-			return &ErrorResponse{
-				Code:    "SAME_DESTINATION_IN_WEEK",
-				Message: "launchpad already used/booked for this destination during requested week",
-			}, nil
+	}
+	response.RefundPercent = refund.Percent
+	response.RefundAmount = refund.Amount
+	return &response, nil
+}
+
+// refundPercent returns percent of the captured price refunded when booking is cancelled, holds which
+// are not confirmed are refunded in full
+func (s *Service) refundPercent(booking *Booking) int {
+	if booking.Status == HELD {
+		return 100
+	}
+	return s.config.Cancellation.RefundPercent(time.Time(booking.LaunchDate))
+}
+
+// RefundCancelled makes pending refunds of cancelled bookings until no refund is due, refund which fails
+// is retried with exponential backoff
+func (s *Service) RefundCancelled() error {
+	for {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		refunds, err := s.paymentRepository.ClaimRefundsTx(tx, refundBatchSize)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		for i := range refunds {
+			if err := s.refundTx(tx, &refunds[i]); err != nil {
+				log.Printf("can't refund booking %s: %s", refunds[i].BookingId, err)
+			}
+		}
+		err = tx.Commit()
+		if err != nil || len(refunds) < refundBatchSize {
+			return err
 		}
 	}
-	err = s.launchRepository.AddTx(tx, &Launch{
-		Id:          booking.Id, // using same id as for booking for simplicity
-		LaunchpadId: booking.LaunchpadId,
-		Date:        booking.LaunchDate,
-	})
+}
+
+// refundTx makes refund locked in the transaction and records the result, error of the refund is returned
+// after its failure is recorded
+func (s *Service) refundTx(tx *sql.Tx, refund *PendingRefund) error {
+	amount, err := s.refundBooking(refund)
+	if err != nil {
+		markErr := s.paymentRepository.MarkRefundFailedTx(tx, refund.BookingId, err.Error(),
+			outboxBackoff(refund.Attempts+1))
+		if markErr != nil {
+			return markErr
+		}
+		return err
+	}
+	refund.Amount = amount
+	return s.paymentRepository.MarkRefundedTx(tx, refund.BookingId, amount)
+}
+
+// refundBooking refunds percent of the price captured for the booking and returns refunded sum. Refunds already
+// made for the booking count towards it, so money is not refunded twice if recording of the result fails
+func (s *Service) refundBooking(refund *PendingRefund) (int64, error) {
+	transactions, err := s.paymentRepository.GetAllForBooking(refund.BookingId)
+	if err != nil {
+		return 0, err
+	}
+	var capture *PaymentTransaction
+	var refunded int64
+	for i, transaction := range transactions {
+		if transaction.Status != payment.SUCCEEDED {
+			continue
+		}
+		switch transaction.Type {
+		case payment.CAPTURE:
+			capture = &transactions[i]
+		case payment.REFUND:
+			refunded += transaction.Amount
+		}
+	}
+	if capture == nil {
+		return 0, nil
+	}
+	amount := capture.Amount * int64(refund.Percent) / 100
+	if amount > capture.Amount {
+		amount = capture.Amount
+	}
+	if amount > refunded {
+		if _, err = s.refundPayment(capture, amount-refunded); err != nil {
+			return 0, err
+		}
+	}
+	return amount, nil
+}
+
+// ChangeBookingStatus moves booking to given status if transition is allowed and clients can set the status,
+// holds are confirmed only with payment by ConfirmHold. On cancellation the launch is deleted if there are
+// no more bookings for it and freed seats are recorded to be offered to the waitlist. Cancellation of already cancelled booking
+// succeeds without changes, so concurrent cancellations of the same booking don't fail
+func (s *Service) ChangeBookingStatus(actor string, id string, status BookingStatus) (*SuccessResponse, error) {
+	if !status.IsSetByClient() {
+		return nil, newError(CodeInvalidStatusTransition, "booking can't be moved to %s by request", status)
+	}
+	tx, _ := s.db.Begin()
+	booking, err := s.mainRepository.GetForUpdateTx(tx, id)
+	if err == sql.ErrNoRows {
+		_ = tx.Rollback()
+		return nil, newError(CodeBookingNotFound, "booking does not exists")
+	}
 	if err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
-	err = s.mainRepository.AddTx(tx, &booking)
+	if booking.Status == CANCELLED && status == CANCELLED {
+		_ = tx.Rollback()
+		return &SuccessResponse{Id: id}, nil
+	}
+	err = s.updateBookingStatusTx(tx, actor, booking, status)
+	if err == nil && status == CANCELLED {
+		err = s.waitlistRepository.AddFreedSeatsTx(tx, booking.LaunchpadId, booking.LaunchDate)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &SuccessResponse{Id: id}, nil
+}
+
+// MarkNoShow marks confirmed booking as no-show after its launch date, so it is not marked as flown and loyalty
+// points are not accrued for it
+func (s *Service) MarkNoShow(actor string, id string) (*SuccessResponse, error) {
+	tx, _ := s.db.Begin()
+	booking, err := s.mainRepository.GetForUpdateTx(tx, id)
+	if err == sql.ErrNoRows {
+		_ = tx.Rollback()
+		return nil, newError(CodeBookingNotFound, "booking does not exists")
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = s.updateBookingStatusTx(tx, actor, booking, NO_SHOW)
 	if err != nil {
 		_ = tx.Rollback()
 		return nil, err
@@ -179,7 +711,1186 @@ func (s *Service) AddBooking(request Request) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &SuccessResponse{Id: booking.Id}, nil
+	return &SuccessResponse{Id: id}, nil
+}
+
+// MarkFlownBookings marks confirmed bookings as flown once no-show window after their launch date is over
+func (s *Service) MarkFlownBookings() error {
+	tx, _ := s.db.Begin()
+	ids, err := s.mainRepository.GetPastConfirmedIdsTx(tx, time.Now().Add(-s.config.NoShowWindow))
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for _, id := range ids {
+		booking, err := s.mainRepository.GetTx(tx, id)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		err = s.updateBookingStatusTx(tx, SystemActor, booking, FLOWN)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		log.Printf("%d bookings are marked as flown", len(ids))
+	}
+	return nil
+}
+
+// checkTransition checks that booking can be moved to given status at given time, booking is marked as flown
+// or no-show only after its launch date
+func checkTransition(booking *Booking, status BookingStatus, now time.Time) error {
+	if !booking.Status.CanTransitionTo(status) {
+		return newError(CodeInvalidStatusTransition, "booking can't be moved from %s to %s", booking.Status, status)
+	}
+	if (status == FLOWN || status == NO_SHOW) && time.Time(booking.LaunchDate).After(now) {
+		return newError(CodeInvalidStatusTransition, "booking can't be moved to %s before its launch date", status)
+	}
+	return nil
+}
+
+// updateBookingStatusTx changes status of the booking if transition is allowed and records the change. Refund of
+// the price captured for cancelled booking is added to be made by RefundCancelled, its launch is deleted if there
+// are no more bookings for it
+func (s *Service) updateBookingStatusTx(tx *sql.Tx, actor string, booking *Booking, status BookingStatus) error {
+	if err := checkTransition(booking, status, time.Now()); err != nil {
+		return err
+	}
+	err := s.mainRepository.UpdateStatusTx(tx, booking.Id, status)
+	if err != nil {
+		return err
+	}
+	updated, err := s.mainRepository.GetTx(tx, booking.Id)
+	if err != nil {
+		return err
+	}
+	action, eventType := "update", BookingUpdated
+	if status == CANCELLED {
+		action, eventType = "cancel", BookingCancelled
+	}
+	err = s.auditTx(tx, actor, action, booking.Id, "booking", booking.Id, booking, updated)
+	if err != nil {
+		return err
+	}
+	err = s.eventTx(tx, booking.Id, eventType, booking.Id, updated)
+	if err != nil {
+		return err
+	}
+	if status == FLOWN {
+		return s.accruePointsTx(tx, updated)
+	}
+	if status != CANCELLED {
+		return nil
+	}
+	err = s.paymentRepository.AddRefundTx(tx, booking.Id, s.refundPercent(booking))
+	if err != nil {
+		return err
+	}
+	err = s.promoRepository.ReleaseTx(tx, booking.Id)
+	if err != nil || booking.LaunchId == "" {
+		return err
+	}
+	launch, err := s.launchRepository.GetTx(tx, booking.LaunchId)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.launchRepository.DeleteIfNotBooked(tx, booking.LaunchId)
+	if err != nil || !deleted {
+		return err
+	}
+	err = s.auditTx(tx, actor, "delete", booking.Id, "launch", launch.Id, launch, nil)
+	if err != nil {
+		return err
+	}
+	return s.eventTx(tx, booking.Id, LaunchRemoved, launch.Id, launch)
+}
+
+// accruePointsTx accrues frequent-flyer points to every passenger of the flown booking
+func (s *Service) accruePointsTx(tx *sql.Tx, booking *Booking) error {
+	destination, err := s.destinationRepository.Get(booking.DestinationId)
+	if err != nil {
+		return err
+	}
+	points := s.config.Loyalty.Points(destination)
+	for _, passenger := range booking.Passengers {
+		newUUID, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		err = s.loyaltyRepository.AddTx(tx, &LoyaltyPoints{
+			Id:        newUUID.String(),
+			ProfileId: passenger.ProfileId,
+			BookingId: booking.Id,
+			Points:    points,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditTx appends change of the booking or of the related entity to the audit log,
+// before and after are snapshots of the entity, nil if entity is created or deleted respectively
+func (s *Service) auditTx(tx *sql.Tx, actor string, action string, bookingId string, entity string, entityId string,
+	before interface{}, after interface{}) error {
+	entry := AuditEntry{
+		BookingId: bookingId,
+		Entity:    entity,
+		EntityId:  entityId,
+		Action:    action,
+		Actor:     actor,
+	}
+	var err error
+	if before != nil {
+		entry.Before, err = json.Marshal(before)
+		if err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		entry.After, err = json.Marshal(after)
+		if err != nil {
+			return err
+		}
+	}
+	return s.auditRepository.AddTx(tx, &entry)
+}
+
+// eventTx appends event of the entity to the event log and to the outbox of the aggregate, e.g. booking,
+// in context of the transaction making the change, so events of rolled back changes are never published.
+// Payload is a snapshot of the entity
+func (s *Service) eventTx(tx *sql.Tx, aggregateId string, eventType EventType, entityId string,
+	payload interface{}) error {
+	event, err := newEvent(eventType, entityId, payload)
+	if err != nil {
+		return err
+	}
+	err = s.eventRepository.AddTx(tx, event)
+	if err != nil {
+		return err
+	}
+	return s.outboxRepository.AddTx(tx, aggregateId, event)
+}
+
+// RecordEvent records event of the entity changed outside of the service, e.g. by import
+func (s *Service) RecordEvent(eventType EventType, entityId string, payload interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = s.eventTx(tx, entityId, eventType, entityId, payload)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetEventsAfter returns events following the event with given id in stream order, at most limit events are returned
+func (s *Service) GetEventsAfter(id int64, limit int) ([]Event, error) {
+	return s.eventRepository.GetAllAfter(id, limit)
+}
+
+// GetLastEventId returns id of the last recorded event, streams without given last event id start after it
+func (s *Service) GetLastEventId() (int64, error) {
+	return s.eventRepository.GetLastId()
+}
+
+// AddWebhook adds webhook subscription to events of given types
+func (s *Service) AddWebhook(request WebhookRequest) (*SuccessResponse, error) {
+	newUUID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	subscription := WebhookSubscription{
+		Id:         newUUID.String(),
+		Url:        request.Url,
+		EventTypes: request.EventTypes,
+		Secret:     request.Secret,
+	}
+	err = s.webhookRepository.Add(&subscription)
+	if err != nil {
+		return nil, err
+	}
+	return &SuccessResponse{Id: subscription.Id}, nil
+}
+
+// GetAllWebhooks returns all webhook subscriptions, secrets are not exposed
+func (s *Service) GetAllWebhooks() (AllWebhooksResponse, error) {
+	return s.webhookRepository.GetAll()
+}
+
+// GetWebhook returns webhook subscription by id
+func (s *Service) GetWebhook(id string) (*WebhookSubscription, error) {
+	subscription, err := s.webhookRepository.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, webhookNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// DeactivateWebhook deactivates webhook subscription, it gets no new deliveries
+func (s *Service) DeactivateWebhook(id string) (*SuccessResponse, error) {
+	deactivated, err := s.webhookRepository.Deactivate(id)
+	if err != nil {
+		return nil, err
+	}
+	if !deactivated {
+		return nil, webhookNotFound(id)
+	}
+	return &SuccessResponse{Id: id}, nil
+}
+
+// GetWebhookDeliveries returns delivery log of the webhook subscription
+func (s *Service) GetWebhookDeliveries(id string) (WebhookDeliveriesResponse, error) {
+	_, err := s.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.webhookRepository.GetAllDeliveries(id)
+}
+
+// RetryWebhookDelivery moves dead delivery of the subscription back to pending, so it is attempted again
+func (s *Service) RetryWebhookDelivery(id string, deliveryId string) (*SuccessResponse, error) {
+	delivery, err := s.webhookRepository.GetDelivery(deliveryId)
+	if err == sql.ErrNoRows || (err == nil && delivery.SubscriptionId != id) {
+		return nil, newError(CodeWebhookDeliveryNotFound, "delivery %s does not exists", deliveryId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	retried, err := s.webhookRepository.RetryDead(deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	if !retried {
+		return nil, newError(CodeWebhookDeliveryNotDead, "delivery %s is %s, only dead deliveries can be retried",
+			deliveryId, delivery.Status)
+	}
+	return &SuccessResponse{Id: deliveryId}, nil
+}
+
+func webhookNotFound(id string) error {
+	return newError(CodeWebhookNotFound, "webhook %s does not exists", id)
+}
+
+// DeliverWebhooks attempts deliveries of events relayed from the outbox which are due. Deliveries are made
+// in parallel, deliveries of the same aggregate to the subscription are made one by one. Failed deliveries
+// are retried with exponential backoff and are dead after the last attempt
+func (s *Service) DeliverWebhooks() error {
+	for {
+		policy := s.config.Webhook
+		due, err := s.webhookRepository.ClaimDue(policy.BatchSize, 2*policy.Timeout)
+		if err != nil {
+			return err
+		}
+		errs := make(chan error, len(due))
+		for i := range due {
+			go func(due *DueDelivery) {
+				errs <- s.deliverWebhook(due)
+			}(&due[i])
+		}
+		for range due {
+			if err := <-errs; err != nil {
+				log.Printf("can't record webhook delivery attempt: %s", err)
+			}
+		}
+		if len(due) < policy.BatchSize {
+			return nil
+		}
+	}
+}
+
+// deliverWebhook attempts delivery and records the attempt
+func (s *Service) deliverWebhook(due *DueDelivery) error {
+	attempt := s.webhookSender.send(due)
+	status := DELIVERED
+	var retryIn time.Duration
+	if attempt.Error != "" {
+		attempts := due.Delivery.Attempts + 1
+		status = PENDING
+		retryIn = s.config.Webhook.Backoff(attempts)
+		if attempts >= s.config.Webhook.MaxAttempts {
+			status = DEAD
+			log.Printf("webhook delivery %s is dead after %d attempts: %s", due.Delivery.Id, attempts, attempt.Error)
+		}
+	}
+	return s.webhookRepository.RecordAttempt(attempt, status, retryIn)
+}
+
+// GetWaitlist returns all waitlist entries
+func (s *Service) GetWaitlist() (AllWaitlistEntriesResponse, error) {
+	return s.waitlistRepository.GetAll()
+}
+
+// AddToWaitlist puts request on the waitlist, it is converted into booking automatically
+// when launchpad becomes available at any date in requested range
+func (s *Service) AddToWaitlist(request WaitlistRequest) (*SuccessResponse, error) {
+	if len(request.Passengers) == 0 {
+		return nil, newError(CodeNoPassengers, "at least one passenger is required")
+	}
+	passengers, err := s.resolveProfiles(request.Passengers)
+	if err != nil {
+		return nil, err
+	}
+	request.Passengers = passengers
+	if time.Time(request.DateTo).Before(time.Time(request.DateFrom)) {
+		return nil, newError(CodeDateRangeIsInvalid, "end of the date range is before its start")
+	}
+	if time.Time(request.DateTo).Before(time.Now()) {
+		return nil, newError(CodeLaunchDateIsInPast, "launch date is in past")
+	}
+	active, err := s.launchpadRepository.ExistsAndIsActive(request.LaunchpadId)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, newError(CodeLaunchpadNotAvailable, "launchpad does not exists or is inactive")
+	}
+	exists, err := s.destinationRepository.Exists(request.DestinationId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, newError(CodeDestinationIsInvalid, "destination does not exists")
+	}
+	newUUID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	entry := WaitlistEntry{
+		Id:            newUUID.String(),
+		LaunchpadId:   request.LaunchpadId,
+		DestinationId: request.DestinationId,
+		DateFrom:      request.DateFrom,
+		DateTo:        request.DateTo,
+		Passengers:    request.Passengers,
+		Status:        WAITING,
+		PaymentToken:  request.PaymentToken,
+		ContactEmail:  request.ContactEmail,
+		Locale:        request.Locale,
+	}
+	if entry.Locale == "" {
+		entry.Locale = defaultLocale
+	}
+	identities := make([]PassengerProfile, 0, len(entry.Passengers))
+	for _, passenger := range entry.Passengers {
+		identities = append(identities, PassengerProfile{FirstName: passenger.FirstName,
+			LastName: passenger.LastName, Birthday: passenger.Birthday})
+	}
+	tx, _ := s.db.Begin()
+	err = s.passengerRepository.LockIdentitiesTx(tx, identities)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	for i, passenger := range entry.Passengers {
+		if passenger.ProfileId != "" {
+			continue
+		}
+		entry.Passengers[i].ProfileId, err = s.findOrAddProfileTx(tx, passenger.FirstName, passenger.LastName,
+			passenger.Gender, passenger.Birthday)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	err = s.waitlistRepository.AddTx(tx, &entry)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &SuccessResponse{Id: entry.Id}, nil
+}
+
+// ProcessWaitlist offers seats freed by cancellations, expired holds and removed launches to the waitlist. Freed
+// seats are recorded along with the change freeing them and the waitlist is processed in background, so requests
+// freeing seats don't wait for bookings and payments of waitlist entries. Seats which can't be offered because
+// of failure are offered again once their lease is over
+func (s *Service) ProcessWaitlist() error {
+	for {
+		freed, err := s.waitlistRepository.ClaimFreedSeats(waitlistBatchSize, waitlistLease)
+		if err != nil {
+			return err
+		}
+		processed := make([]int64, 0, len(freed))
+		failures := make(map[string]error)
+		for _, seats := range freed {
+			key := seats.LaunchpadId + "|" + seats.LaunchDate.Format("2006-01-02")
+			failure, done := failures[key]
+			if !done {
+				failure = s.processWaitlist(seats.LaunchpadId, seats.LaunchDate)
+				if failure != nil {
+					log.Printf("can't process waitlist for %s: %s", key, failure)
+				}
+				failures[key] = failure
+			}
+			if failure == nil {
+				processed = append(processed, seats.Id)
+			}
+		}
+		if len(processed) > 0 {
+			if err := s.waitlistRepository.DeleteFreedSeats(processed); err != nil {
+				return err
+			}
+		}
+		if len(freed) < waitlistBatchSize {
+			return nil
+		}
+	}
+}
+
+// processWaitlist tries to book waitlisted requests for given launchpad and date in order they were added,
+// entries with passengers of loyalty tiers having priority waitlist go first. All entries are tried, so every seat
+// freed is taken, outcome of every failed attempt is recorded on the entry. Booking is paid with payment token
+// of the entry right away, entry keeps waiting if payment fails
+func (s *Service) processWaitlist(launchpadId string, date Date) error {
+	if time.Time(date).Before(time.Now()) {
+		return nil
+	}
+	entries, err := s.waitlistRepository.GetWaitingFor(launchpadId, date)
+	if err != nil {
+		return err
+	}
+	err = s.prioritizeWaitlist(entries)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		expiresAt := time.Now().Add(s.config.HoldTTL)
+		hold, err := s.addBooking(SystemActor, GroupRequest{
+			Passengers:    entry.Passengers,
+			LaunchpadId:   entry.LaunchpadId,
+			DestinationId: entry.DestinationId,
+			LaunchDate:    date,
+			PaymentToken:  entry.PaymentToken,
+			ContactEmail:  entry.ContactEmail,
+			Locale:        entry.Locale,
+		}, HELD, &expiresAt, entry.Id)
+		if err == errWaitlistEntryNotWaiting {
+			continue
+		}
+		if err == nil {
+			// seats of the booking cancelled because of failed payment are offered to the rest of entries
+			err = s.payHold(SystemActor, hold.Id, entry.PaymentToken)
+		}
+		if isDomainError(err) {
+			err = s.waitlistRepository.RecordOutcome(entry.Id, asError(err).Code)
+			if err != nil {
+				log.Printf("can't record waitlist entry %s outcome: %s", entry.Id, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("can't book waitlist entry %s: %w", entry.Id, err)
+		}
+		log.Printf("waitlist entry %s is booked as %s", entry.Id, hold.Id)
+	}
+	return nil
+}
+
+// prioritizeWaitlist moves entries with passengers having priority waitlist benefit ahead of other entries,
+// order of entries is kept otherwise
+func (s *Service) prioritizeWaitlist(entries []WaitlistEntry) error {
+	profileIds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		for _, passenger := range entry.Passengers {
+			if passenger.ProfileId != "" {
+				profileIds = append(profileIds, passenger.ProfileId)
+			}
+		}
+	}
+	if len(profileIds) == 0 {
+		return nil
+	}
+	balances, err := s.loyaltyRepository.GetBalances(profileIds)
+	if err != nil {
+		return err
+	}
+	priority := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		for _, passenger := range entry.Passengers {
+			tier, _ := s.config.Loyalty.Tier(balances[passenger.ProfileId])
+			if tier != nil && tier.PriorityWaitlist {
+				priority[entry.Id] = true
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return priority[entries[i].Id] && !priority[entries[j].Id]
+	})
+	return nil
+}
+
+// AddBooking adds booking for a single passenger
+func (s *Service) AddBooking(actor string, request Request) (*SuccessResponse, error) {
+	return s.AddGroupBooking(actor, GroupRequest{
+		Passengers: []PassengerRequest{{
+			FirstName: request.FirstName,
+			LastName:  request.LastName,
+			Gender:    request.Gender,
+			Birthday:  request.Birthday,
+		}},
+		LaunchpadId:   request.LaunchpadId,
+		DestinationId: request.DestinationId,
+		LaunchDate:    request.LaunchDate,
+		PaymentToken:  request.PaymentToken,
+		PromoCodes:    request.PromoCodes,
+		ContactEmail:  request.ContactEmail,
+		Locale:        request.Locale,
+	})
+}
+
+// Quote calculates itemized price of the prospective booking, seats availability is not checked
+func (s *Service) Quote(request GroupRequest) (*Quote, error) {
+	passengers, err := s.resolveProfiles(request.Passengers)
+	if err != nil {
+		return nil, err
+	}
+	request.Passengers = passengers
+	err = s.validateRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	quote, _, err := s.priceRequest(request)
+	return quote, err
+}
+
+// priceRequest calculates price of the request with promo codes applied, returns redemptions of the promo codes
+func (s *Service) priceRequest(request GroupRequest) (*Quote, []PromoRedemption, error) {
+	quote, err := s.fareCalculator.Quote(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	promos := make([]PromoCode, 0, len(request.PromoCodes))
+	for _, code := range request.PromoCodes {
+		promo, err := s.promoRepository.Get(normalizePromoCode(code))
+		if err == sql.ErrNoRows {
+			return nil, nil, newError(CodePromoCodeIsInvalid, "promo code %s does not exists", code)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		promos = append(promos, *promo)
+	}
+	redemptions, err := applyPromoCodes(quote, request, promos, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return quote, redemptions, nil
+}
+
+// AddGroupBooking adds booking for all passengers of the request atomically, all passengers fly on the same launch.
+// Seats are held until payment is captured, booking is confirmed only after successful payment
+func (s *Service) AddGroupBooking(actor string, request GroupRequest) (*SuccessResponse, error) {
+	expiresAt := time.Now().Add(s.config.HoldTTL)
+	booking, err := s.addBooking(actor, request, HELD, &expiresAt, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.confirmWithPayment(actor, booking.Id, request.PaymentToken)
+}
+
+// HoldSeats reserves seats for all passengers of the request using the same rules as for booking,
+// hold has to be confirmed with ConfirmHold before it expires, otherwise seats are released
+func (s *Service) HoldSeats(actor string, request GroupRequest) (*HoldResponse, error) {
+	expiresAt := time.Now().Add(s.config.HoldTTL)
+	booking, err := s.addBooking(actor, request, HELD, &expiresAt, "")
+	if err != nil {
+		return nil, err
+	}
+	return &HoldResponse{
+		Id:         booking.Id,
+		ExpiresAt:  expiresAt,
+		TTLSeconds: int(s.config.HoldTTL.Seconds()),
+	}, nil
+}
+
+// ConfirmHold converts hold into confirmed booking with the same id after payment is captured
+func (s *Service) ConfirmHold(actor string, id string, paymentToken string) (*SuccessResponse, error) {
+	return s.confirmWithPayment(actor, id, paymentToken)
+}
+
+// GetBookingPayments returns payment transactions of the booking
+func (s *Service) GetBookingPayments(id string) (AllPaymentsResponse, error) {
+	_, err := s.mainRepository.Get(id)
+	if err == sql.ErrNoRows {
+		return nil, newError(CodeBookingNotFound, "booking does not exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.paymentRepository.GetAllForBooking(id)
+	if err != nil {
+		return nil, err
+	}
+	return AllPaymentsResponse(transactions), nil
+}
+
+// confirmWithPayment charges price of the held booking and confirms it, booking is cancelled if payment fails
+// and its seats are offered to the waitlist
+func (s *Service) confirmWithPayment(actor string, id string, paymentToken string) (*SuccessResponse, error) {
+	err := s.payHold(actor, id, paymentToken)
+	if err != nil {
+		return nil, err
+	}
+	return &SuccessResponse{Id: id}, nil
+}
+
+// payHold charges price of the held booking and confirms it. Hold is locked while payment is made, so it is neither
+// paid twice nor released meanwhile. Booking is cancelled if payment fails or hold expires while payment is made,
+// waitlist entry the booking is made for is put back to the waitlist
+func (s *Service) payHold(actor string, id string, paymentToken string) error {
+	tx, _ := s.db.Begin()
+	booking, err := s.mainRepository.GetForUpdateTx(tx, id)
+	if err != nil && err != sql.ErrNoRows {
+		_ = tx.Rollback()
+		return err
+	}
+	if err = holdError(booking); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	capture, err := s.chargeBooking(booking, paymentToken)
+	if err == nil {
+		err = holdError(booking)
+	}
+	if err != nil {
+		// captured payment of the hold expired meanwhile is refunded with cancellation
+		if cancelErr := s.cancelUnpaidBookingTx(tx, actor, booking, err); cancelErr != nil {
+			log.Printf("can't cancel unpaid booking %s: %s", booking.Id, cancelErr)
+			s.refundUnconfirmed(capture)
+		}
+		return err
+	}
+
+	err = s.updateBookingStatusTx(tx, actor, booking, CONFIRMED)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		_ = tx.Rollback()
+	}
+	if err != nil {
+		s.refundUnconfirmed(capture)
+		return err
+	}
+	return nil
+}
+
+// refundUnconfirmed refunds payment captured for the hold which failed to be confirmed or cancelled
+func (s *Service) refundUnconfirmed(capture *PaymentTransaction) {
+	if capture == nil {
+		return
+	}
+	if _, err := s.refundPayment(capture, capture.Amount); err != nil {
+		log.Printf("can't refund payment %s of booking %s: %s", capture.Id, capture.BookingId, err)
+	}
+}
+
+// holdError checks that booking is held and hold is not expired
+func holdError(booking *Booking) error {
+	if booking == nil || booking.Status != HELD {
+		return newError(CodeHoldNotFound, "hold does not exists or is not held anymore")
+	}
+	if !booking.HoldExpiresAt.After(time.Now()) {
+		return newError(CodeHoldExpired, "hold is expired")
+	}
+	return nil
+}
+
+// chargeBooking authorizes and captures price of the booking, capture transaction is returned.
+// Nothing is charged for free bookings
+func (s *Service) chargeBooking(booking *Booking, paymentToken string) (*PaymentTransaction, error) {
+	if booking.Price == 0 {
+		return nil, nil
+	}
+	transaction, err := s.paymentProvider.Authorize(paymentToken, booking.Price, booking.Currency)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := s.recordPayment(booking.Id, booking.Currency, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if authorization.Status != payment.SUCCEEDED {
+		return nil, paymentDeclined(authorization)
+	}
+	transaction, err = s.paymentProvider.Capture(authorization.Reference, booking.Price)
+	if err != nil {
+		return nil, err
+	}
+	capture, err := s.recordPayment(booking.Id, booking.Currency, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if capture.Status != payment.SUCCEEDED {
+		return nil, paymentDeclined(capture)
+	}
+	return capture, nil
+}
+
+// refundPayment refunds given amount of the captured payment
+func (s *Service) refundPayment(capture *PaymentTransaction, amount int64) (*PaymentTransaction, error) {
+	transaction, err := s.paymentProvider.Refund(capture.Reference, amount)
+	if err != nil {
+		return nil, err
+	}
+	refund, err := s.recordPayment(capture.BookingId, capture.Currency, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != payment.SUCCEEDED {
+		return refund, fmt.Errorf("refund is declined: %s", refund.Message)
+	}
+	return refund, nil
+}
+
+// recordPayment stores result of the payment operation
+func (s *Service) recordPayment(bookingId string, currency string, transaction *payment.Transaction) (*PaymentTransaction, error) {
+	newUUID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Currency != "" {
+		currency = transaction.Currency
+	}
+	record := PaymentTransaction{
+		Id:        newUUID.String(),
+		BookingId: bookingId,
+		Type:      transaction.Type,
+		Reference: transaction.Reference,
+		Amount:    transaction.Amount,
+		Currency:  currency,
+		Status:    transaction.Status,
+		Message:   transaction.Message,
+	}
+	err = s.paymentRepository.Add(&record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func paymentDeclined(transaction *PaymentTransaction) error {
+	return newError(CodePaymentDeclined, "payment is declined: %s", transaction.Message)
+}
+
+// cancelUnpaidBookingTx cancels locked booking which can't be paid and commits the transaction, so its seats
+// are released immediately. Waitlist entry the booking is made for keeps waiting with the payment error as outcome
+// and its seats are offered to the rest of the waitlist being processed, seats of other bookings are recorded
+// to be offered to the waitlist
+func (s *Service) cancelUnpaidBookingTx(tx *sql.Tx, actor string, booking *Booking, reason error) error {
+	err := s.updateBookingStatusTx(tx, actor, booking, CANCELLED)
+	requeued := false
+	if err == nil {
+		requeued, err = s.waitlistRepository.RequeueTx(tx, booking.Id, asError(reason).Code)
+	}
+	if err == nil && !requeued {
+		err = s.waitlistRepository.AddFreedSeatsTx(tx, booking.LaunchpadId, booking.LaunchDate)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReleaseExpiredHolds cancels expired holds and deletes their launches if there are no more bookings for them,
+// freed seats are recorded to be offered to the waitlist
+func (s *Service) ReleaseExpiredHolds() error {
+	tx, _ := s.db.Begin()
+	ids, err := s.mainRepository.GetExpiredHoldIdsTx(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	released, err := s.releaseHoldsTx(tx, ids)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	for _, booking := range released {
+		log.Printf("hold %s is expired and released", booking.Id)
+	}
+	return nil
+}
+
+// releaseHoldsTx cancels holds with given ids and records their seats to be offered to the waitlist, ids are
+// expected to be locked
+func (s *Service) releaseHoldsTx(tx *sql.Tx, ids []string) ([]Booking, error) {
+	released := make([]Booking, 0, len(ids))
+	for _, id := range ids {
+		booking, err := s.mainRepository.GetTx(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		err = s.updateBookingStatusTx(tx, SystemActor, booking, CANCELLED)
+		if err == nil {
+			err = s.waitlistRepository.AddFreedSeatsTx(tx, booking.LaunchpadId, booking.LaunchDate)
+		}
+		if err != nil {
+			return nil, err
+		}
+		released = append(released, *booking)
+	}
+	return released, nil
+}
+
+// addBooking validates request and adds booking with given status, waitlist entry the booking is made for
+// is marked as booked in the same transaction
+func (s *Service) addBooking(actor string, request GroupRequest, status BookingStatus, holdExpiresAt *time.Time,
+	waitlistEntryId string) (*Booking, error) {
+	passengers, err := s.resolveProfiles(request.Passengers)
+	if err != nil {
+		return nil, err
+	}
+	request.Passengers = passengers
+	err = s.validateRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	newUUID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	capacity, err := s.launchpadRepository.GetCapacity(request.LaunchpadId)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Passengers) > capacity {
+		return nil, newError(CodeCapacityExceeded, "launch can't take more than %d passengers", capacity)
+	}
+	quote, redemptions, err := s.priceRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	booking := Booking{
+		Id:            newUUID.String(),
+		Status:        status,
+		HoldExpiresAt: holdExpiresAt,
+		LaunchpadId:   request.LaunchpadId,
+		DestinationId: request.DestinationId,
+		LaunchDate:    request.LaunchDate,
+		Price:         quote.Total,
+		Currency:      quote.Currency,
+		ContactEmail:  request.ContactEmail,
+		Locale:        request.Locale,
+		Passengers:    make([]Passenger, 0, len(request.Passengers)),
+	}
+	if booking.Locale == "" {
+		booking.Locale = defaultLocale
+	}
+	for _, passenger := range request.Passengers {
+		passengerUUID, err := uuid.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+		booking.Passengers = append(booking.Passengers, Passenger{
+			Id:        passengerUUID.String(),
+			ProfileId: passenger.ProfileId,
+			FirstName: passenger.FirstName,
+			LastName:  passenger.LastName,
+			Gender:    passenger.Gender,
+			Birthday:  passenger.Birthday,
+		})
+	}
+
+	tx, _ := s.db.Begin()
+	released, err := s.releaseExpiredHoldsInWeekTx(tx, booking.LaunchpadId, booking.LaunchDate)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	launchId, err := s.reserveSeatsTx(tx, actor, &booking, capacity)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	booking.LaunchId = launchId
+	err = s.addProfilesTx(tx, &booking)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = s.checkEligibilityTx(tx, &booking)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = s.mainRepository.AddTx(tx, &booking)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if waitlistEntryId != "" {
+		booked, err := s.waitlistRepository.MarkBookedTx(tx, waitlistEntryId, booking.Id)
+		if err == nil && !booked {
+			err = errWaitlistEntryNotWaiting
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	err = s.auditTx(tx, actor, "create", booking.Id, "booking", booking.Id, nil, &booking)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = s.eventTx(tx, booking.Id, BookingCreated, booking.Id, &booking)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = s.redeemPromoCodesTx(tx, booking.Id, redemptions)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	for _, hold := range released {
+		log.Printf("hold %s is expired and released", hold.Id)
+	}
+	return &booking, nil
+}
+
+// releaseExpiredHoldsInWeekTx releases expired holds from the launchpad in the week of given date, so their
+// launches don't block the launchpad for the booking being added. Other holds are left to ReleaseExpiredHolds
+func (s *Service) releaseExpiredHoldsInWeekTx(tx *sql.Tx, launchpadId string, date Date) ([]Booking, error) {
+	ids, err := s.mainRepository.GetExpiredHoldIdsInWeekTx(tx, launchpadId, date)
+	if err != nil {
+		return nil, err
+	}
+	return s.releaseHoldsTx(tx, ids)
+}
+
+// redeemPromoCodesTx records redemptions of the promo codes applied to the booking, usage limits are checked
+// once again as codes could be used up since the price was calculated
+func (s *Service) redeemPromoCodesTx(tx *sql.Tx, bookingId string,
+	redemptions []PromoRedemption) error {
+	for _, redemption := range redemptions {
+		newUUID, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		redemption.Id = newUUID.String()
+		redemption.BookingId = bookingId
+		redeemed, err := s.promoRepository.RedeemTx(tx, &redemption)
+		if err != nil {
+			return err
+		}
+		if !redeemed {
+			return newError(CodePromoCodeIsExhausted, "promo code %s is used up", redemption.Code)
+		}
+	}
+	return nil
+}
+
+// resolveProfiles fills name, gender and birthday of passengers referenced by profile id
+func (s *Service) resolveProfiles(passengers []PassengerRequest) ([]PassengerRequest, error) {
+	resolved := make([]PassengerRequest, 0, len(passengers))
+	for _, passenger := range passengers {
+		if passenger.ProfileId != "" {
+			profile, err := s.getPassenger(passenger.ProfileId)
+			if isDomainError(err) {
+				return nil, newError(CodePassengerIsInvalid, "passenger %s does not exists", passenger.ProfileId)
+			}
+			if err != nil {
+				return nil, err
+			}
+			passenger = PassengerRequest{
+				ProfileId: profile.Id,
+				FirstName: profile.FirstName,
+				LastName:  profile.LastName,
+				Gender:    profile.Gender,
+				Birthday:  profile.Birthday,
+			}
+		}
+		resolved = append(resolved, passenger)
+	}
+	return resolved, nil
+}
+
+// addProfilesTx links passengers of the booking not referencing profile to existing profiles with the same
+// identity, new profiles are added for unknown passengers. Identities of all passengers are locked at once before
+// profiles are looked up, so concurrent bookings neither add the same passenger twice nor deadlock
+func (s *Service) addProfilesTx(tx *sql.Tx, booking *Booking) error {
+	identities := make([]PassengerProfile, 0, len(booking.Passengers))
+	for _, passenger := range booking.Passengers {
+		identities = append(identities, PassengerProfile{FirstName: passenger.FirstName,
+			LastName: passenger.LastName, Birthday: passenger.Birthday})
+	}
+	err := s.passengerRepository.LockIdentitiesTx(tx, identities)
+	if err != nil {
+		return err
+	}
+	for i, passenger := range booking.Passengers {
+		if passenger.ProfileId != "" {
+			continue
+		}
+		profileId, err := s.findOrAddProfileTx(tx, passenger.FirstName, passenger.LastName, passenger.Gender,
+			passenger.Birthday)
+		if err != nil {
+			return err
+		}
+		booking.Passengers[i].ProfileId = profileId
+	}
+	return nil
+}
+
+// checkEligibilityTx checks that passengers of the booking are not travelling during the mission of the booking,
+// passengers are locked until the end of the given transaction, so they can't be booked concurrently
+func (s *Service) checkEligibilityTx(tx *sql.Tx, booking *Booking) error {
+	ids := make([]string, 0, len(booking.Passengers))
+	names := make(map[string]string, len(booking.Passengers))
+	for _, passenger := range booking.Passengers {
+		name := fmt.Sprintf("%s %s", passenger.FirstName, passenger.LastName)
+		if _, ok := names[passenger.ProfileId]; ok {
+			return newError(CodePassengerAlreadyTravelling, "passenger %s is given more than once", name)
+		}
+		names[passenger.ProfileId] = name
+		ids = append(ids, passenger.ProfileId)
+	}
+	destination, err := s.destinationRepository.Get(booking.DestinationId)
+	if err != nil {
+		return err
+	}
+	err = s.passengerRepository.LockTx(tx, ids)
+	if err != nil {
+		return err
+	}
+	from := booking.LaunchDate
+	to := Date(time.Time(from).AddDate(0, 0, destination.MissionDays))
+	travelling, err := s.passengerRepository.GetTravellingTx(tx, ids, from, to)
+	if err != nil {
+		return err
+	}
+	if len(travelling) > 0 {
+		return newError(CodePassengerAlreadyTravelling,
+			"passenger %s is already travelling during %d days mission from %s",
+			names[travelling[0]], destination.MissionDays, from.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// findOrAddProfileTx returns id of the passenger profile with given identity, profile is added if it is not found
+func (s *Service) findOrAddProfileTx(tx *sql.Tx, firstName string, lastName string, gender Gender,
+	birthday Date) (string, error) {
+	newUUID, err := uuid.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	profile := PassengerProfile{
+		Id:        newUUID.String(),
+		FirstName: firstName,
+		LastName:  lastName,
+		Gender:    gender,
+		Birthday:  birthday,
+	}
+	err = s.passengerRepository.FindOrAddTx(tx, &profile)
+	if err != nil {
+		return "", err
+	}
+	return profile.Id, nil
+}
+
+// validateRequest checks that booking can be made for the request regardless of availability of seats
+func (s *Service) validateRequest(request GroupRequest) error {
+	if len(request.Passengers) == 0 {
+		return newError(CodeNoPassengers, "at least one passenger is required")
+	}
+	if time.Time(request.LaunchDate).Before(time.Now()) {
+		return newError(CodeLaunchDateIsInPast, "launch date is in past")
+	}
+	active, err := s.launchpadRepository.ExistsAndIsActive(request.LaunchpadId)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return newError(CodeLaunchpadNotAvailable, "launchpad does not exists or is inactive")
+	}
+	exists, err := s.destinationRepository.Exists(request.DestinationId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newError(CodeDestinationIsInvalid, "destination does not exists")
+	}
+	return nil
+}
+
+// reserveSeatsTx finds launch for the booking and checks that it has enough seats for all passengers,
+// new launch with given capacity is created if there is no launch from the launchpad at requested date
+func (s *Service) reserveSeatsTx(tx *sql.Tx, actor string, booking *Booking, capacity int) (string, error) {
+	launches, err := s.launchRepository.GetAllFromLaunchpadAtDateForUpdate(tx, booking.LaunchpadId, booking.LaunchDate)
+	if err != nil {
+		return "", err
+	}
+	if len(launches) > 0 {
+		launch := launches[0]
+		if launch.DestinationId != booking.DestinationId {
+			return "", newError(CodeLaunchpadBusy, "launchpad is busy at given date")
+		}
+		if launch.RemainingSeats < len(booking.Passengers) {
+			return "", newError(CodeLaunchFull, "launch has only %d seats left", launch.RemainingSeats)
+		}
+		return launch.Id, nil
+	}
+
+	weekLaunches, err := s.launchRepository.GetWeekLaunches(tx, booking.LaunchpadId, booking.LaunchDate)
+	if err != nil {
+		return "", err
+	}
+	for _, launch := range weekLaunches {
+		if launch.DestinationId == booking.DestinationId {
+			return "", newError(CodeSameDestinationInWeek,
+				"launchpad already used/booked for this destination during requested week")
+		}
+	}
+	launchUUID, err := uuid.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	launch := Launch{
+		Id:             launchUUID.String(),
+		LaunchpadId:    booking.LaunchpadId,
+		DestinationId:  booking.DestinationId,
+		Date:           booking.LaunchDate,
+		Capacity:       capacity,
+		RemainingSeats: capacity,
+	}
+	err = s.launchRepository.AddTx(tx, &launch)
+	if err != nil {
+		return "", err
+	}
+	err = s.auditTx(tx, actor, "create", booking.Id, "launch", launch.Id, nil, &launch)
+	if err != nil {
+		return "", err
+	}
+	return launchUUID.String(), nil
 }
 
 // PingDb pings db, to determine if db is available and schema created
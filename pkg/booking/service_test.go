@@ -0,0 +1,135 @@
+package booking
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckTransition(t *testing.T) {
+	now := time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)
+	past := Date(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	today := Date(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC))
+	future := Date(time.Date(2030, 1, 3, 0, 0, 0, 0, time.UTC))
+	cases := []struct {
+		from       BookingStatus
+		to         BookingStatus
+		launchDate Date
+		allowed    bool
+	}{
+		{CONFIRMED, FLOWN, past, true},
+		{CONFIRMED, NO_SHOW, past, true},
+		{CONFIRMED, NO_SHOW, today, true},
+		{CONFIRMED, FLOWN, future, false},
+		{CONFIRMED, NO_SHOW, future, false},
+		{CONFIRMED, CANCELLED, future, true},
+		{HELD, CONFIRMED, future, true},
+		{HELD, FLOWN, past, false},
+		{HELD, NO_SHOW, past, false},
+		{CANCELLED, NO_SHOW, past, false},
+		{FLOWN, NO_SHOW, past, false},
+		{NO_SHOW, FLOWN, past, false},
+	}
+	for _, c := range cases {
+		err := checkTransition(&Booking{Status: c.from, LaunchDate: c.launchDate}, c.to, now)
+		if c.allowed && err != nil {
+			t.Errorf("expected %s booking launching %s to be moved to %s, got %s", c.from,
+				c.launchDate.Format("2006-01-02"), c.to, err)
+		}
+		if !c.allowed && (err == nil || asError(err).Code != CodeInvalidStatusTransition) {
+			t.Errorf("expected %s booking launching %s not to be moved to %s, got %v", c.from,
+				c.launchDate.Format("2006-01-02"), c.to, err)
+		}
+	}
+}
+
+// fakeWaitlist keeps freed seats in memory, waitlist of the broken launchpad can't be read
+type fakeWaitlist struct {
+	freed           []FreedSeats
+	claimed         map[int64]bool
+	brokenLaunchpad string
+	processed       []string
+}
+
+func (f *fakeWaitlist) AddTx(*sql.Tx, *WaitlistEntry) error {
+	return nil
+}
+
+func (f *fakeWaitlist) GetAll() ([]WaitlistEntry, error) {
+	return nil, nil
+}
+
+func (f *fakeWaitlist) GetWaitingFor(launchpadId string, _ Date) ([]WaitlistEntry, error) {
+	f.processed = append(f.processed, launchpadId)
+	if launchpadId == f.brokenLaunchpad {
+		return nil, errors.New("connection is lost")
+	}
+	return []WaitlistEntry{}, nil
+}
+
+func (f *fakeWaitlist) MarkBookedTx(*sql.Tx, string, string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeWaitlist) RequeueTx(*sql.Tx, string, string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeWaitlist) RecordOutcome(string, string) error {
+	return nil
+}
+
+func (f *fakeWaitlist) AddFreedSeats(launchpadId string, date Date) error {
+	f.freed = append(f.freed, FreedSeats{Id: int64(len(f.freed) + 1), LaunchpadId: launchpadId, LaunchDate: date})
+	return nil
+}
+
+func (f *fakeWaitlist) AddFreedSeatsTx(_ *sql.Tx, launchpadId string, date Date) error {
+	return f.AddFreedSeats(launchpadId, date)
+}
+
+func (f *fakeWaitlist) ClaimFreedSeats(limit int, _ time.Duration) ([]FreedSeats, error) {
+	claimed := make([]FreedSeats, 0)
+	for _, seats := range f.freed {
+		if !f.claimed[seats.Id] && len(claimed) < limit {
+			f.claimed[seats.Id] = true
+			claimed = append(claimed, seats)
+		}
+	}
+	return claimed, nil
+}
+
+func (f *fakeWaitlist) DeleteFreedSeats(ids []int64) error {
+	deleted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	kept := make([]FreedSeats, 0, len(f.freed))
+	for _, seats := range f.freed {
+		if !deleted[seats.Id] {
+			kept = append(kept, seats)
+		}
+	}
+	f.freed = kept
+	return nil
+}
+
+func TestFreedSeatsAreKeptUntilWaitlistIsProcessed(t *testing.T) {
+	launchDate := Date(time.Now().AddDate(0, 1, 0))
+	waitlist := &fakeWaitlist{claimed: make(map[int64]bool), brokenLaunchpad: "broken"}
+	_ = waitlist.AddFreedSeats("kennedy", launchDate)
+	_ = waitlist.AddFreedSeats("broken", launchDate)
+	_ = waitlist.AddFreedSeats("kennedy", launchDate)
+	service := &Service{waitlistRepository: waitlist}
+
+	if err := service.ProcessWaitlist(); err != nil {
+		t.Fatalf("can't process waitlist: %s", err)
+	}
+	if len(waitlist.processed) != 2 {
+		t.Errorf("expected waitlist of every launch to be processed once, got %v", waitlist.processed)
+	}
+	if len(waitlist.freed) != 1 || waitlist.freed[0].LaunchpadId != "broken" {
+		t.Errorf("expected only seats of the broken launchpad to be kept, got %+v", waitlist.freed)
+	}
+}
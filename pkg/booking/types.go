@@ -6,23 +6,32 @@ import (
 	"time"
 )
 
-// Gender represents gender of the passenger
+// Gender represents gender of the passenger, allowed values are kept in gender_option table
 type Gender string
 
 const (
-	MALE   = Gender("Male")
-	FEMALE = Gender("Female")
+	MALE        = Gender("Male")
+	FEMALE      = Gender("Female")
+	NON_BINARY  = Gender("NonBinary")
+	UNSPECIFIED = Gender("Unspecified")
 )
 
-// Date represents date without time
+// Date represents date without time, it is serialized as YYYY-MM-DD
 type Date time.Time
 
-// UnmarshalJSON Implement Unmarshaler interface
+// dateLayout is a layout of serialized date
+const dateLayout = "2006-01-02"
+
+// UnmarshalJSON Implement Unmarshaler interface, RFC 3339 timestamps sent by legacy clients are accepted as well
 func (d *Date) UnmarshalJSON(b []byte) error {
 	s := strings.Trim(string(b), "\"")
-	t, err := time.Parse("2006-01-02", s)
+	t, err := time.Parse(dateLayout, s)
 	if err != nil {
-		return err
+		timestamp, timestampErr := time.Parse(time.RFC3339, s)
+		if timestampErr != nil {
+			return err
+		}
+		t = time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), 0, 0, 0, 0, time.UTC)
 	}
 	*d = Date(t)
 	return nil
@@ -30,7 +39,7 @@ func (d *Date) UnmarshalJSON(b []byte) error {
 
 // MarshalJSON Implement Marshaler interface
 func (d Date) MarshalJSON() ([]byte, error) {
-	return json.Marshal(time.Time(d))
+	return json.Marshal(d.Format(dateLayout))
 }
 
 // Format Maybe a Format function for printing your date
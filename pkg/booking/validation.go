@@ -0,0 +1,287 @@
+package booking
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxNameLength is a length of name columns in database
+const maxNameLength = 256
+
+// maxEmailLength is a length of contact email column in database
+const maxEmailLength = 254
+
+// maxUrlLength is a length of webhook URL column in database
+const maxUrlLength = 2048
+
+// minSecretLength is a minimum length of webhook secret, so signatures can't be forged by guessing it
+const minSecretLength = 16
+
+var launchpadIdPattern = regexp.MustCompile("^[0-9a-f]{24}$")
+
+// FieldError describes invalid field of the request, Field is a path to the field, e.g. passengers[0].birthday
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// validator collects all invalid fields of the request
+type validator struct {
+	genders []Gender
+	errors  []FieldError
+}
+
+func newValidator(genders []Gender) *validator {
+	return &validator{genders: genders, errors: make([]FieldError, 0)}
+}
+
+// response returns validation error listing invalid fields, nil if request is valid
+func (v *validator) response() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	err := newError(CodeValidationFailed, "request has %d invalid fields", len(v.errors))
+	err.Fields = v.errors
+	return err
+}
+
+func (v *validator) fail(field string, reason string) {
+	v.errors = append(v.errors, FieldError{Field: field, Reason: reason})
+}
+
+func (v *validator) request(request Request) {
+	v.name("firstName", request.FirstName)
+	v.name("lastName", request.LastName)
+	v.gender("gender", request.Gender)
+	v.birthday("birthday", request.Birthday)
+	v.launch("", request.LaunchpadId, request.DestinationId)
+	v.date("launchDate", request.LaunchDate)
+	v.promoCodes(request.PromoCodes)
+	v.contact(request.ContactEmail, request.Locale)
+}
+
+func (v *validator) groupRequest(request GroupRequest) {
+	v.passengers(request.Passengers)
+	v.launch("", request.LaunchpadId, request.DestinationId)
+	v.date("launchDate", request.LaunchDate)
+	v.promoCodes(request.PromoCodes)
+	v.contact(request.ContactEmail, request.Locale)
+}
+
+func (v *validator) waitlistRequest(request WaitlistRequest) {
+	v.passengers(request.Passengers)
+	v.launch("", request.LaunchpadId, request.DestinationId)
+	v.date("dateFrom", request.DateFrom)
+	v.date("dateTo", request.DateTo)
+	if time.Time(request.DateTo).Before(time.Time(request.DateFrom)) {
+		v.fail("dateTo", "should not be before dateFrom")
+	}
+	if len(request.PaymentToken) > maxNameLength {
+		v.fail("paymentToken", fmt.Sprintf("should be at most %d characters", maxNameLength))
+	}
+	v.contact(request.ContactEmail, request.Locale)
+}
+
+func (v *validator) passengerProfileRequest(request PassengerProfileRequest) {
+	v.name("firstName", request.FirstName)
+	v.name("lastName", request.LastName)
+	v.gender("gender", request.Gender)
+	v.birthday("birthday", request.Birthday)
+}
+
+func (v *validator) statusRequest(request StatusRequest) {
+	switch {
+	case request.Status == "":
+		v.fail("status", "is required")
+	case !request.Status.IsSetByClient():
+		names := make([]string, 0, len(clientStatuses))
+		for _, status := range clientStatuses {
+			names = append(names, string(status))
+		}
+		v.fail("status", fmt.Sprintf("should be one of %s", strings.Join(names, ", ")))
+	}
+}
+
+func (v *validator) mergeRequest(request MergeRequest) {
+	if len(request.PassengerIds) == 0 {
+		v.fail("passengerIds", "at least one passenger is required")
+	}
+	for i, id := range request.PassengerIds {
+		v.uuid(fmt.Sprintf("passengerIds[%d]", i), id)
+	}
+}
+
+func (v *validator) webhookRequest(request WebhookRequest) {
+	switch parsed, err := url.Parse(request.Url); {
+	case request.Url == "":
+		v.fail("url", "is required")
+	case err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "":
+		v.fail("url", "should be absolute http or https URL")
+	case len(request.Url) > maxUrlLength:
+		v.fail("url", fmt.Sprintf("should be at most %d characters", maxUrlLength))
+	}
+	if len(request.EventTypes) == 0 {
+		v.fail("eventTypes", "at least one event type is required")
+	}
+	for i, eventType := range request.EventTypes {
+		if !isEventType(eventType) {
+			v.fail(fmt.Sprintf("eventTypes[%d]", i), fmt.Sprintf("should be one of %s",
+				strings.Join(eventTypeNames(eventTypes), ", ")))
+		}
+	}
+	switch {
+	case request.Secret == "":
+		v.fail("secret", "is required")
+	case len(request.Secret) < minSecretLength || len(request.Secret) > maxNameLength:
+		v.fail("secret", fmt.Sprintf("should be %d to %d characters", minSecretLength, maxNameLength))
+	}
+}
+
+func (v *validator) promoCodeRequest(request PromoCodeRequest) {
+	if !promoCodePattern.MatchString(normalizePromoCode(request.Code)) {
+		v.fail("code", "should be 1 to 32 letters, digits, underscores or dashes")
+	}
+	if utf8.RuneCountInString(request.Description) > maxNameLength {
+		v.fail("description", fmt.Sprintf("should be at most %d characters", maxNameLength))
+	}
+	switch {
+	case request.PercentOff < 0 || request.PercentOff > 100:
+		v.fail("percentOff", "should be between 0 and 100")
+	case request.AmountOff < 0:
+		v.fail("amountOff", "should not be negative")
+	case request.PercentOff != 0 && request.AmountOff != 0:
+		v.fail("amountOff", "should not be given along with percentOff")
+	case request.PercentOff == 0 && request.AmountOff == 0:
+		v.fail("percentOff", "either percentOff or amountOff is required")
+	}
+	if request.ValidFrom != nil && request.ValidTo != nil && !request.ValidTo.After(*request.ValidFrom) {
+		v.fail("validTo", "should be after validFrom")
+	}
+	if request.LaunchDateFrom != nil && request.LaunchDateTo != nil &&
+		time.Time(*request.LaunchDateTo).Before(time.Time(*request.LaunchDateFrom)) {
+		v.fail("launchDateTo", "should not be before launchDateFrom")
+	}
+	if request.DestinationId != nil {
+		v.uuid("destinationId", *request.DestinationId)
+	}
+	if request.LaunchpadId != nil && !launchpadIdPattern.MatchString(*request.LaunchpadId) {
+		v.fail("launchpadId", "should be 24 hexadecimal digits")
+	}
+	if request.MaxUses != nil && *request.MaxUses <= 0 {
+		v.fail("maxUses", "should be positive")
+	}
+}
+
+func isEventType(eventType EventType) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) passengers(passengers []PassengerRequest) {
+	if len(passengers) == 0 {
+		v.fail("passengers", "at least one passenger is required")
+	}
+	for i, passenger := range passengers {
+		prefix := fmt.Sprintf("passengers[%d].", i)
+		if passenger.ProfileId != "" {
+			v.uuid(prefix+"profileId", passenger.ProfileId)
+			continue
+		}
+		v.name(prefix+"firstName", passenger.FirstName)
+		v.name(prefix+"lastName", passenger.LastName)
+		v.gender(prefix+"gender", passenger.Gender)
+		v.birthday(prefix+"birthday", passenger.Birthday)
+	}
+}
+
+func (v *validator) launch(prefix string, launchpadId string, destinationId string) {
+	switch {
+	case launchpadId == "":
+		v.fail(prefix+"launchpadId", "is required")
+	case !launchpadIdPattern.MatchString(launchpadId):
+		v.fail(prefix+"launchpadId", "should be 24 hexadecimal digits")
+	}
+	v.uuid(prefix+"destinationId", destinationId)
+}
+
+// contact validates optional contact email and locale of emails sent to it
+func (v *validator) contact(email string, locale string) {
+	if address, err := mail.ParseAddress(email); email != "" && (err != nil || address.Address != email) {
+		v.fail("contactEmail", "should be email address")
+	} else if len(email) > maxEmailLength {
+		v.fail("contactEmail", fmt.Sprintf("should be at most %d characters", maxEmailLength))
+	}
+	if locale != "" && !isLocale(locale) {
+		v.fail("locale", fmt.Sprintf("should be one of %s", strings.Join(locales(), ", ")))
+	}
+}
+
+func (v *validator) promoCodes(codes []string) {
+	for i, code := range codes {
+		if !promoCodePattern.MatchString(normalizePromoCode(code)) {
+			v.fail(fmt.Sprintf("promoCodes[%d]", i), "should be 1 to 32 letters, digits, underscores or dashes")
+		}
+	}
+}
+
+func (v *validator) name(field string, name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		v.fail(field, "is required")
+	case utf8.RuneCountInString(name) > maxNameLength:
+		v.fail(field, fmt.Sprintf("should be at most %d characters", maxNameLength))
+	}
+}
+
+func (v *validator) gender(field string, gender Gender) {
+	if gender == "" {
+		v.fail(field, "is required")
+		return
+	}
+	for _, g := range v.genders {
+		if g == gender {
+			return
+		}
+	}
+	allowed := make([]string, 0, len(v.genders))
+	for _, g := range v.genders {
+		allowed = append(allowed, string(g))
+	}
+	v.fail(field, fmt.Sprintf("should be one of %s", strings.Join(allowed, ", ")))
+}
+
+func (v *validator) birthday(field string, birthday Date) {
+	switch {
+	case time.Time(birthday).IsZero():
+		v.fail(field, "is required")
+	case time.Time(birthday).After(time.Now()):
+		v.fail(field, "should not be in future")
+	}
+}
+
+func (v *validator) date(field string, date Date) {
+	if time.Time(date).IsZero() {
+		v.fail(field, "is required")
+	}
+}
+
+func (v *validator) uuid(field string, id string) {
+	if id == "" {
+		v.fail(field, "is required")
+		return
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		v.fail(field, "should be UUID")
+	}
+}
@@ -0,0 +1,118 @@
+package booking
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of webhook requests, signature is HMAC-SHA256 of timestamp and body joined with a dot
+const (
+	WebhookIdHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookPolicy describes how events are delivered to webhook subscriptions
+type WebhookPolicy struct {
+	// Timeout is a maximum time of a single delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is a number of attempts after which delivery is dead
+	MaxAttempts int
+	// InitialBackoff is a delay before the second attempt, the delay is doubled after every failed attempt
+	InitialBackoff time.Duration
+	// MaxBackoff limits delay between attempts
+	MaxBackoff time.Duration
+	// BatchSize is a maximum number of deliveries attempted at once
+	BatchSize int
+}
+
+// DefaultWebhookPolicy returns default webhook policy, dead deliveries are given up after about a day
+func DefaultWebhookPolicy() WebhookPolicy {
+	return WebhookPolicy{
+		Timeout:        10 * time.Second,
+		MaxAttempts:    12,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     6 * time.Hour,
+		BatchSize:      50,
+	}
+}
+
+// Backoff returns delay before the next attempt after given number of failed attempts
+func (p WebhookPolicy) Backoff(attempts int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempts && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// SignWebhook returns signature of the webhook body sent at given unix timestamp, receivers compute it with
+// the secret of the subscription and compare with X-Webhook-Signature header
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSender posts events to webhook subscriptions
+type webhookSender struct {
+	client *http.Client
+}
+
+func newWebhookSender(timeout time.Duration) *webhookSender {
+	return &webhookSender{client: &http.Client{Timeout: timeout}}
+}
+
+// send posts the event of the delivery to the subscription URL, attempt is successful if response status is 2xx
+func (s *webhookSender) send(due *DueDelivery) *WebhookAttempt {
+	attempt := WebhookAttempt{DeliveryId: due.Delivery.Id}
+	started := time.Now()
+	statusCode, err := s.post(due)
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	attempt.StatusCode = statusCode
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return &attempt
+}
+
+func (s *webhookSender) post(due *DueDelivery) (int, error) {
+	body, err := json.Marshal(&due.Event)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequest(http.MethodPost, due.Subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookIdHeader, due.Delivery.Id)
+	request.Header.Set(WebhookEventHeader, string(due.Event.Type))
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(WebhookSignatureHeader, SignWebhook(due.Subscription.Secret, timestamp, body))
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxBodySize))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("receiver responded with %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
@@ -0,0 +1,85 @@
+package booking
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+func testDelivery(url string) *DueDelivery {
+	return &DueDelivery{
+		Delivery:     WebhookDelivery{Id: "f0f3c6a4-52a5-11ed-bdc3-0242ac120002"},
+		Subscription: WebhookSubscription{Url: url, Secret: testSecret},
+		Event: Event{
+			Id:       42,
+			Type:     BookingCreated,
+			EntityId: "0b4c9c5e-52a6-11ed-bdc3-0242ac120002",
+			Payload:  json.RawMessage(`{"id":"0b4c9c5e-52a6-11ed-bdc3-0242ac120002"}`),
+		},
+	}
+}
+
+func TestWebhookIsSigned(t *testing.T) {
+	var received Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("invalid timestamp %q", r.Header.Get(WebhookTimestampHeader))
+		}
+		if signature := r.Header.Get(WebhookSignatureHeader); signature != SignWebhook(testSecret, timestamp, body) {
+			t.Errorf("invalid signature %q", signature)
+		}
+		if event := r.Header.Get(WebhookEventHeader); event != string(BookingCreated) {
+			t.Errorf("unexpected event type %q", event)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("can't parse body: %s", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	attempt := newWebhookSender(time.Second).send(testDelivery(receiver.URL))
+	if attempt.Error != "" || attempt.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected successful attempt, got status %d and error %q", attempt.StatusCode, attempt.Error)
+	}
+	if received.Id != 42 || received.Type != BookingCreated {
+		t.Errorf("unexpected event received: %+v", received)
+	}
+}
+
+func TestWebhookFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	attempt := newWebhookSender(time.Second).send(testDelivery(receiver.URL))
+	if attempt.Error == "" || attempt.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected failed attempt with status 503, got status %d and error %q", attempt.StatusCode,
+			attempt.Error)
+	}
+
+	receiver.Close()
+	attempt = newWebhookSender(time.Second).send(testDelivery(receiver.URL))
+	if attempt.Error == "" || attempt.StatusCode != 0 {
+		t.Errorf("expected failed attempt without status, got status %d and error %q", attempt.StatusCode,
+			attempt.Error)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	policy := WebhookPolicy{InitialBackoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute,
+		5 * time.Minute}
+	for i, backoff := range expected {
+		if actual := policy.Backoff(i + 1); actual != backoff {
+			t.Errorf("expected backoff %s after %d attempts, got %s", backoff, i+1, actual)
+		}
+	}
+}
@@ -10,6 +10,11 @@ type Executor interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 func Connect(info string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", info)
 	if err != nil {
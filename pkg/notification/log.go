@@ -0,0 +1,54 @@
+package notification
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logNotifier struct{}
+
+// NewLogNotifier creates notifier for local development which writes messages to the log instead of sending them
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+// Send logs the message
+func (logNotifier) Send(message *Message) error {
+	log.Printf("email to %s: %s\n%s", message.To, message.Subject, strings.TrimRight(message.Body, "\n"))
+	return nil
+}
+
+type fileNotifier struct {
+	mutex sync.Mutex
+	dir   string
+	from  string
+	count int
+}
+
+// NewFileNotifier creates notifier for local development which writes every message into .eml file
+// in given directory, files can be opened with mail client
+func NewFileNotifier(dir string, from string) Notifier {
+	return &fileNotifier{dir: dir, from: from}
+}
+
+// Send writes the message into a new file named after the time it is sent and its number
+func (f *fileNotifier) Send(message *Message) error {
+	now := time.Now()
+	data, err := compose(f.from, message, now)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	f.count++
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405"), f.count)
+	return os.WriteFile(filepath.Join(f.dir, name), data, 0o644)
+}
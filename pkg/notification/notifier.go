@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email message
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier sends messages to customers, errors are returned if message can't be sent
+type Notifier interface {
+	Send(message *Message) error
+}
+
+// IsPermanent reports whether sending failed permanently, e.g. mail server rejected the recipient,
+// so the message should not be sent again
+func IsPermanent(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
+
+// compose formats the message as RFC 5322 message sent at given time, body is encoded as quoted-printable
+func compose(from string, message *Message, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(from+message.To, "\r\n") {
+		return nil, fmt.Errorf("address of the message should not contain line breaks")
+	}
+	buffer := bytes.Buffer{}
+	buffer.WriteString("From: " + from + "\r\n")
+	buffer.WriteString("To: " + message.To + "\r\n")
+	buffer.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", oneLine(message.Subject)) + "\r\n")
+	buffer.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	writer := quotedprintable.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(message.Body)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
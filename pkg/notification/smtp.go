@@ -0,0 +1,32 @@
+package notification
+
+import (
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+type smtpNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPNotifier creates notifier sending messages through SMTP server at given address, auth is optional.
+// Connection is upgraded with STARTTLS if server supports it
+func NewSMTPNotifier(addr string, from string, auth smtp.Auth) Notifier {
+	return &smtpNotifier{addr: addr, from: from, auth: auth}
+}
+
+// Send sends the message, rejections of the server are returned as *textproto.Error
+func (s *smtpNotifier) Send(message *Message) error {
+	data, err := compose(s.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, sender.Address, []string{message.To}, data)
+}
@@ -0,0 +1,134 @@
+package notification
+
+import (
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts messages on a local port, recipients listed in reject are refused
+type fakeSMTPServer struct {
+	listener net.Listener
+	reject   map[string]bool
+	messages chan string
+}
+
+func newFakeSMTPServer(t *testing.T, reject ...string) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("can't listen: %s", err)
+	}
+	server := &fakeSMTPServer{listener: listener, reject: map[string]bool{}, messages: make(chan string, 1)}
+	for _, recipient := range reject {
+		server.reject[recipient] = true
+	}
+	go server.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(textproto.NewConn(conn))
+	}
+}
+
+func (s *fakeSMTPServer) session(conn *textproto.Conn) {
+	defer conn.Close()
+	_ = conn.PrintfLine("220 localhost fake SMTP")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO", "MAIL", "RSET", "NOOP":
+			_ = conn.PrintfLine("250 OK")
+		case "RCPT":
+			recipient := strings.Trim(strings.TrimPrefix(line[len(command):], " TO:"), "<>")
+			if s.reject[recipient] {
+				_ = conn.PrintfLine("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			_ = conn.PrintfLine("250 OK")
+		case "DATA":
+			_ = conn.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(conn.DotReader())
+			if err != nil {
+				return
+			}
+			s.messages <- string(data)
+			_ = conn.PrintfLine("250 OK")
+		case "QUIT":
+			_ = conn.PrintfLine("221 bye")
+			return
+		default:
+			_ = conn.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifierSendsMessage(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	notifier := NewSMTPNotifier(server.listener.Addr().String(), "Space Booking <no-reply@example.com>", nil)
+	err := notifier.Send(&Message{
+		To:      "traveller@example.com",
+		Subject: "Бронювання підтверджено",
+		Body:    "Booking 42 is confirmed.\nLaunch date: 2030-01-02\n",
+	})
+	if err != nil {
+		t.Fatalf("can't send message: %s", err)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(<-server.messages))
+	if err != nil {
+		t.Fatalf("can't parse sent message: %s", err)
+	}
+	if to := message.Header.Get("To"); to != "traveller@example.com" {
+		t.Errorf("unexpected recipient %q", to)
+	}
+	subject, err := (&mime.WordDecoder{}).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != "Бронювання підтверджено" {
+		t.Errorf("unexpected subject %q: %v", subject, err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(message.Body))
+	if err != nil {
+		t.Fatalf("can't decode body: %s", err)
+	}
+	if string(body) != "Booking 42 is confirmed.\nLaunch date: 2030-01-02\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestSMTPNotifierRejectedRecipient(t *testing.T) {
+	server := newFakeSMTPServer(t, "nobody@example.com")
+	notifier := NewSMTPNotifier(server.listener.Addr().String(), "no-reply@example.com", nil)
+	err := notifier.Send(&Message{To: "nobody@example.com", Subject: "Booking", Body: "Body"})
+	if err == nil || !IsPermanent(err) {
+		t.Errorf("expected permanent error, got %v", err)
+	}
+
+	server.listener.Close()
+	err = notifier.Send(&Message{To: "traveller@example.com", Subject: "Booking", Body: "Body"})
+	if err == nil || IsPermanent(err) {
+		t.Errorf("expected temporary error, got %v", err)
+	}
+}
+
+func TestNotifierRejectsHeaderInjection(t *testing.T) {
+	notifier := NewFileNotifier(t.TempDir(), "no-reply@example.com")
+	err := notifier.Send(&Message{To: "traveller@example.com\r\nBcc: other@example.com", Subject: "Booking"})
+	if err == nil {
+		t.Error("expected error for recipient with line break")
+	}
+}
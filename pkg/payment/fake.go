@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DeclineAuthorizationToken is a token fake provider declines authorization for
+	DeclineAuthorizationToken = "tok_decline"
+	// DeclineCaptureToken is a token fake provider declines capture for
+	DeclineCaptureToken = "tok_decline_capture"
+)
+
+type operation struct {
+	token    string
+	amount   int64
+	currency string
+	refunded int64
+}
+
+type fakeProvider struct {
+	mutex      sync.Mutex
+	operations map[string]*operation
+}
+
+// NewFakeProvider creates in-memory payment provider for local development and testing, all payments succeed
+// except payments with empty token or one of the decline tokens
+func NewFakeProvider() Provider {
+	return &fakeProvider{
+		operations: make(map[string]*operation),
+	}
+}
+
+// Authorize reserves given amount
+func (f *fakeProvider) Authorize(token string, amount int64, currency string) (*Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if token == "" {
+		return f.declined(AUTHORIZE, amount, currency, "payment token is missing"), nil
+	}
+	if token == DeclineAuthorizationToken {
+		return f.declined(AUTHORIZE, amount, currency, "insufficient funds"), nil
+	}
+	return f.succeeded(AUTHORIZE, &operation{token: token, amount: amount, currency: currency}), nil
+}
+
+// Capture charges previously authorized amount
+func (f *fakeProvider) Capture(authorization string, amount int64) (*Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	authorized, ok := f.operations[authorization]
+	if !ok {
+		return f.declined(CAPTURE, amount, "", "authorization does not exists"), nil
+	}
+	if authorized.token == DeclineCaptureToken {
+		return f.declined(CAPTURE, amount, authorized.currency, "capture is declined"), nil
+	}
+	if amount > authorized.amount {
+		return f.declined(CAPTURE, amount, authorized.currency, "amount exceeds authorized amount"), nil
+	}
+	delete(f.operations, authorization)
+	return f.succeeded(CAPTURE, &operation{token: authorized.token, amount: amount, currency: authorized.currency}), nil
+}
+
+// Refund returns previously captured amount, several partial refunds are allowed
+func (f *fakeProvider) Refund(capture string, amount int64) (*Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	captured, ok := f.operations[capture]
+	if !ok {
+		return f.declined(REFUND, amount, "", "capture does not exists"), nil
+	}
+	if captured.refunded+amount > captured.amount {
+		return f.declined(REFUND, amount, captured.currency,
+			fmt.Sprintf("only %d can be refunded", captured.amount-captured.refunded)), nil
+	}
+	captured.refunded += amount
+	return f.succeeded(REFUND, &operation{token: captured.token, amount: amount, currency: captured.currency}), nil
+}
+
+func (f *fakeProvider) succeeded(t Type, op *operation) *Transaction {
+	reference := uuid.NewString()
+	f.operations[reference] = op
+	return &Transaction{
+		Reference: reference,
+		Type:      t,
+		Amount:    op.amount,
+		Currency:  op.currency,
+		Status:    SUCCEEDED,
+	}
+}
+
+func (f *fakeProvider) declined(t Type, amount int64, currency string, message string) *Transaction {
+	return &Transaction{
+		Reference: uuid.NewString(),
+		Type:      t,
+		Amount:    amount,
+		Currency:  currency,
+		Status:    DECLINED,
+		Message:   message,
+	}
+}
@@ -0,0 +1,39 @@
+package payment
+
+// Type type of the payment transaction
+type Type string
+
+const (
+	AUTHORIZE = Type("authorize")
+	CAPTURE   = Type("capture")
+	REFUND    = Type("refund")
+)
+
+// Status status of the payment transaction
+type Status string
+
+const (
+	SUCCEEDED = Status("succeeded")
+	DECLINED  = Status("declined")
+)
+
+// Transaction is a result of the operation made by payment provider, declined transactions are not errors,
+// Message contains reason of the decline. Amount is in cents
+type Transaction struct {
+	Reference string
+	Type      Type
+	Amount    int64
+	Currency  string
+	Status    Status
+	Message   string
+}
+
+// Provider is a payment provider, errors are returned only if provider is not available
+type Provider interface {
+	// Authorize reserves given amount on the payment method identified by token
+	Authorize(token string, amount int64, currency string) (*Transaction, error)
+	// Capture charges amount previously reserved with authorization identified by reference
+	Capture(authorization string, amount int64) (*Transaction, error)
+	// Refund returns amount charged with capture identified by reference
+	Refund(capture string, amount int64) (*Transaction, error)
+}